@@ -0,0 +1,742 @@
+// Package caldav implements a minimal CalDAV (RFC 4791) server
+// exposing every list of a repository as a calendar collection of VTODOs.
+//
+// The URL layout relative to the handler's prefix is:
+//
+//	/                  principal and calendar home
+//	/{list}/           calendar collection
+//	/{list}/{uid}.ics  calendar object resource
+//
+// Resource names must equal the UID of their VTODO. List names and UIDs
+// are path segments escaped by url.PathEscape. Archived todos are hidden.
+package caldav
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/ical"
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// XML namespaces.
+const (
+	nsDAV    = "DAV:"
+	nsCalDAV = "urn:ietf:params:xml:ns:caldav"
+	nsCS     = "http://calendarserver.org/ns/"
+)
+
+const contentTypeTodo = "text/calendar; charset=utf-8; component=vtodo"
+
+// maxBodySize limits the size of request bodies.
+const maxBodySize = 1 << 20
+
+var (
+	propResourceType       = xml.Name{Space: nsDAV, Local: "resourcetype"}
+	propDisplayName        = xml.Name{Space: nsDAV, Local: "displayname"}
+	propCurrentPrincipal   = xml.Name{Space: nsDAV, Local: "current-user-principal"}
+	propPrincipalURL       = xml.Name{Space: nsDAV, Local: "principal-URL"}
+	propOwner              = xml.Name{Space: nsDAV, Local: "owner"}
+	propPrivilegeSet       = xml.Name{Space: nsDAV, Local: "current-user-privilege-set"}
+	propSupportedReportSet = xml.Name{Space: nsDAV, Local: "supported-report-set"}
+	propGetETag            = xml.Name{Space: nsDAV, Local: "getetag"}
+	propGetContentType     = xml.Name{Space: nsDAV, Local: "getcontenttype"}
+	propCalendarHomeSet    = xml.Name{Space: nsCalDAV, Local: "calendar-home-set"}
+	propSupportedCompSet   = xml.Name{Space: nsCalDAV, Local: "supported-calendar-component-set"}
+	propCalendarData       = xml.Name{Space: nsCalDAV, Local: "calendar-data"}
+	propGetCTag            = xml.Name{Space: nsCS, Local: "getctag"}
+)
+
+// Handler serves CalDAV requests.
+type Handler struct {
+	prefix string
+	repo   *repository.Repository
+}
+
+var _ http.Handler = new(Handler)
+
+// NewHandler creates a new CalDAV handler for requests under the given path prefix
+// which must start and end with a slash (e.g. "/caldav/").
+func NewHandler(repo *repository.Repository, prefix string) *Handler {
+	return &Handler{prefix: prefix, repo: repo}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	list, name, ok := h.parsePath(r.URL.EscapedPath())
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.Header().Set("DAV", "1, 3, calendar-access")
+		w.Header().Set("Allow",
+			"OPTIONS, GET, HEAD, PUT, DELETE, PROPFIND, REPORT")
+	case "PROPFIND":
+		h.handlePropfind(w, r, list, name)
+	case "REPORT":
+		h.handleReport(w, r, list, name)
+	case http.MethodGet, http.MethodHead:
+		h.handleGet(w, r, list, name)
+	case http.MethodPut:
+		h.handlePut(w, r, list, name)
+	case http.MethodDelete:
+		h.handleDelete(w, r, list, name)
+	default:
+		const code = http.StatusMethodNotAllowed
+		http.Error(w, http.StatusText(code), code)
+	}
+}
+
+// parsePath returns the unescaped list and resource name
+// of the escaped path p, list="" for the root, name="" for collections.
+// hrefList and hrefTodo escape them.
+func (h *Handler) parsePath(p string) (list, name string, ok bool) {
+	rest, ok := strings.CutPrefix(p, h.prefix)
+	if !ok {
+		if p+"/" == h.prefix {
+			return "", "", true
+		}
+		return "", "", false
+	}
+	if rest == "" {
+		return "", "", true
+	}
+	segments := strings.Split(rest, "/")
+	switch {
+	case len(segments) == 1 && segments[0] != "":
+		// Collection without a trailing slash.
+	case len(segments) == 2 && segments[1] == "":
+	case len(segments) == 2 && strings.HasSuffix(segments[1], ".ics"):
+		name = strings.TrimSuffix(segments[1], ".ics")
+	default:
+		return "", "", false
+	}
+	if segments[0] == "" {
+		return "", "", false
+	}
+	list, err := url.PathUnescape(segments[0])
+	if err != nil {
+		return "", "", false
+	}
+	if name, err = url.PathUnescape(name); err != nil {
+		return "", "", false
+	}
+	return list, name, true
+}
+
+func (h *Handler) hrefRoot() string { return h.prefix }
+
+func (h *Handler) hrefList(list string) string {
+	return h.prefix + url.PathEscape(list) + "/"
+}
+
+func (h *Handler) hrefTodo(t repository.Todo) string {
+	return h.hrefList(t.List) + url.PathEscape(ical.UID(t)) + ".ics"
+}
+
+// ETag returns the strong entity tag of t, which is its version
+// and the same as the one of the web interface.
+func ETag(t repository.Todo) string {
+	return `"` + strconv.FormatUint(t.Version, 10) + `"`
+}
+
+// ctag changes whenever any todo in the list changes.
+// The IDs are included since versions are only unique per todo.
+func ctag(todos []repository.Todo) string {
+	hash := sha256.New()
+	for _, t := range todos {
+		_, _ = io.WriteString(hash, t.ID+":"+ETag(t)+"\n")
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:8])
+}
+
+// lookup returns the todo of list with the given UID (see ical.UID),
+// which may be archived.
+func lookup(tx *repository.Tx, list, uid string) (repository.Todo, bool, error) {
+	t, err := tx.GetByUID(uid)
+	if errors.Is(err, repository.ErrNotFound) {
+		if id, ok := strings.CutSuffix(uid, ical.UIDSuffix); ok {
+			t, err = tx.Get(id)
+		}
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		return repository.Todo{}, false, nil
+	} else if err != nil {
+		return repository.Todo{}, false, err
+	}
+	if t.List != list || ical.UID(t) != uid {
+		return repository.Todo{}, false, nil
+	}
+	return t, true, nil
+}
+
+// get returns the unarchived todo of list with the given UID.
+func (h *Handler) get(list, uid string) (t repository.Todo, ok bool, err error) {
+	err = h.repo.View(func(tx *repository.Tx) error {
+		t, ok, err = lookup(tx, list, uid)
+		return err
+	})
+	return t, ok && !t.Archived, err
+}
+
+// listExists returns the unarchived todos of list
+// and true for lists with todos and repository.DefaultList.
+func (h *Handler) listExists(list string) (todos []repository.Todo, ok bool, err error) {
+	todos, err = h.repo.InList(list)
+	if err != nil {
+		return nil, false, err
+	}
+	ok = len(todos) > 0 || list == repository.DefaultList
+	return unarchived(todos), ok, nil
+}
+
+func unarchived(todos []repository.Todo) []repository.Todo {
+	return slices.DeleteFunc(todos, func(t repository.Todo) bool { return t.Archived })
+}
+
+// merge returns existing with the fields package ical maps replaced by
+// those of t. Fields without an iCalendar representation are kept.
+func merge(existing, t repository.Todo) repository.Todo {
+	m := existing
+	m.Title, m.Done, m.Priority = t.Title, t.Done, t.Priority
+	m.Completed, m.Due, m.TimeZone = t.Completed, t.Due, t.TimeZone
+	m.Recurrence, m.Contexts = t.Recurrence, t.Contexts
+	if !t.Created.IsZero() {
+		m.Created = t.Created
+	}
+	return m
+}
+
+// errPrecondition is returned within transactions
+// if If-Match or If-None-Match don't hold.
+var errPrecondition = errors.New("precondition failed")
+
+func (h *Handler) handleGet(w http.ResponseWriter, r *http.Request, list, name string) {
+	var todos []repository.Todo
+	switch {
+	case list == "":
+		http.Error(w, "not a calendar", http.StatusNotFound)
+		return
+	case name == "":
+		var ok bool
+		var err error
+		if todos, ok, err = h.listExists(list); err != nil {
+			internalErr(w, err, "getting list todos")
+			return
+		} else if !ok {
+			http.NotFound(w, r)
+			return
+		}
+	default:
+		t, ok, err := h.get(list, name)
+		if err != nil {
+			internalErr(w, err, "looking up todo")
+			return
+		} else if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", ETag(t))
+		todos = []repository.Todo{t}
+	}
+
+	w.Header().Set("Content-Type", ical.ContentType)
+	if err := ical.Write(w, list, todos, time.Now()); err != nil {
+		slog.Error("writing ics", slog.Any("err", err))
+	}
+}
+
+func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request, list, name string) {
+	if name == "" {
+		http.Error(w, "can't PUT a collection", http.StatusMethodNotAllowed)
+		return
+	}
+	todos, err := ical.Read(r.Body, time.Local)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("parsing ics: %v", err), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case len(todos) < 1:
+		writeError(w, http.StatusForbidden,
+			xml.Name{Space: nsCalDAV, Local: "supported-calendar-component"})
+		return
+	case len(todos) > 1:
+		writeError(w, http.StatusForbidden,
+			xml.Name{Space: nsCalDAV, Local: "valid-calendar-object-resource"})
+		return
+	}
+	t := todos[0]
+	if t.UID == "" {
+		t.UID = name
+	} else if t.UID != name {
+		http.Error(w, "resource name must equal the UID", http.StatusBadRequest)
+		return
+	}
+	t.List = list
+
+	// Look up, check and write atomically so that concurrent
+	// requests can't both pass the preconditions.
+	code := http.StatusCreated
+	var stored repository.Todo
+	err = h.repo.Update(func(tx *repository.Tx) error {
+		existing, exists, err := lookup(tx, list, name)
+		if err != nil {
+			return err
+		}
+		if !preconditionsHold(r, existing, exists) {
+			return errPrecondition
+		}
+		id := existing.ID
+		if exists {
+			code = http.StatusNoContent
+			if err := tx.Replace(merge(existing, t)); err != nil {
+				return fmt.Errorf("updating todo: %w", err)
+			}
+		} else {
+			ids, err := tx.Import([]repository.Todo{t}, time.Now())
+			if err != nil {
+				return fmt.Errorf("creating todo: %w", err)
+			}
+			id = ids[0]
+		}
+		stored, err = tx.Get(id)
+		return err
+	})
+	if errors.Is(err, errPrecondition) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	} else if err != nil {
+		internalErr(w, err, "storing todo")
+		return
+	}
+	w.Header().Set("ETag", ETag(stored))
+	w.WriteHeader(code)
+}
+
+func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request, list, name string) {
+	if name == "" {
+		http.Error(w, "can't DELETE a collection", http.StatusForbidden)
+		return
+	}
+	found := true
+	err := h.repo.Update(func(tx *repository.Tx) error {
+		t, exists, err := lookup(tx, list, name)
+		if err != nil {
+			return err
+		}
+		if !exists || t.Archived {
+			found = false
+			return nil
+		}
+		if !preconditionsHold(r, t, true) {
+			return errPrecondition
+		}
+		return tx.Remove(t.ID)
+	})
+	switch {
+	case errors.Is(err, errPrecondition):
+		w.WriteHeader(http.StatusPreconditionFailed)
+	case err != nil:
+		internalErr(w, err, "removing todo")
+	case !found:
+		http.NotFound(w, r)
+	default:
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// preconditionsHold evaluates If-Match and If-None-Match.
+func preconditionsHold(r *http.Request, t repository.Todo, exists bool) bool {
+	if m := r.Header.Get("If-Match"); m != "" {
+		if !exists || (m != "*" && !etagListContains(m, ETag(t))) {
+			return false
+		}
+	}
+	if m := r.Header.Get("If-None-Match"); m != "" && exists {
+		if m == "*" || etagListContains(m, ETag(t)) {
+			return false
+		}
+	}
+	return true
+}
+
+func etagListContains(list, etag string) bool {
+	for _, e := range strings.Split(list, ",") {
+		if strings.TrimPrefix(strings.TrimSpace(e), "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// propRequest is the <D:prop> element of PROPFIND and REPORT requests.
+type propRequest struct {
+	Names []struct {
+		XMLName xml.Name
+	} `xml:",any"`
+}
+
+func (p *propRequest) names() []xml.Name {
+	if p == nil {
+		return nil
+	}
+	n := make([]xml.Name, len(p.Names))
+	for i := range p.Names {
+		n[i] = p.Names[i].XMLName
+	}
+	return n
+}
+
+type propfindRequest struct {
+	XMLName  xml.Name     `xml:"DAV: propfind"`
+	AllProp  *struct{}    `xml:"DAV: allprop"`
+	PropName *struct{}    `xml:"DAV: propname"`
+	Prop     *propRequest `xml:"DAV: prop"`
+}
+
+func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request, list, name string) {
+	var req propfindRequest
+	if err := decodeXMLBody(r, &req); err != nil {
+		http.Error(w, fmt.Sprintf("parsing propfind: %v", err), http.StatusBadRequest)
+		return
+	}
+	// An empty body or <D:allprop/> requests all properties.
+	allProps := req.Prop == nil
+	requested := req.Prop.names()
+	depth1 := r.Header.Get("Depth") != "0"
+
+	var responses []response
+	switch {
+	case list == "":
+		responses = append(responses, h.rootResponse())
+		if depth1 {
+			for _, l := range h.repo.Lists() {
+				todos, err := h.repo.InList(l)
+				if err != nil {
+					internalErr(w, err, "getting list todos")
+					return
+				}
+				responses = append(responses, h.listResponse(l, unarchived(todos)))
+			}
+		}
+	case name == "":
+		todos, ok, err := h.listExists(list)
+		if err != nil {
+			internalErr(w, err, "getting list todos")
+			return
+		} else if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		responses = append(responses, h.listResponse(list, todos))
+		if depth1 {
+			for _, t := range todos {
+				responses = append(responses, h.todoResponse(t, false))
+			}
+		}
+	default:
+		t, ok, err := h.get(list, name)
+		if err != nil {
+			internalErr(w, err, "looking up todo")
+			return
+		} else if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		responses = append(responses, h.todoResponse(t, false))
+	}
+	writeMultistatus(w, responses, requested, allProps, req.PropName != nil)
+}
+
+type reportRequest struct {
+	XMLName xml.Name
+	Prop    *propRequest `xml:"DAV: prop"`
+	Hrefs   []string     `xml:"DAV: href"`
+	Filter  *struct {
+		CompFilter compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+	} `xml:"urn:ietf:params:xml:ns:caldav filter"`
+}
+
+type compFilter struct {
+	Name        string       `xml:"name,attr"`
+	CompFilters []compFilter `xml:"urn:ietf:params:xml:ns:caldav comp-filter"`
+}
+
+// matchesTodos returns false if the filter can't match any VTODO.
+// Filters on properties and time ranges are not evaluated.
+func (f compFilter) matchesTodos() bool {
+	if !strings.EqualFold(f.Name, "VCALENDAR") {
+		return false
+	}
+	if len(f.CompFilters) < 1 {
+		return true
+	}
+	for _, c := range f.CompFilters {
+		if strings.EqualFold(c.Name, "VTODO") {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *Handler) handleReport(w http.ResponseWriter, r *http.Request, list, name string) {
+	if list == "" || name != "" {
+		http.Error(w, "REPORT is only supported on calendars", http.StatusForbidden)
+		return
+	}
+	var req reportRequest
+	if err := decodeXMLBody(r, &req); err != nil {
+		http.Error(w, fmt.Sprintf("parsing report: %v", err), http.StatusBadRequest)
+		return
+	}
+	todos, ok, err := h.listExists(list)
+	if err != nil {
+		internalErr(w, err, "getting list todos")
+		return
+	} else if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	var responses []response
+	switch req.XMLName {
+	case xml.Name{Space: nsCalDAV, Local: "calendar-query"}:
+		if req.Filter != nil && !req.Filter.CompFilter.matchesTodos() {
+			break
+		}
+		for _, t := range todos {
+			responses = append(responses, h.todoResponse(t, true))
+		}
+	case xml.Name{Space: nsCalDAV, Local: "calendar-multiget"}:
+		byUID := make(map[string]repository.Todo, len(todos))
+		for _, t := range todos {
+			byUID[ical.UID(t)] = t
+		}
+		for _, href := range req.Hrefs {
+			var t repository.Todo
+			found := false
+			if u, err := url.Parse(href); err == nil {
+				l, name, ok := h.parsePath(u.EscapedPath())
+				if ok && l == list && name != "" {
+					t, found = byUID[name]
+				}
+			}
+			if found {
+				responses = append(responses, h.todoResponse(t, true))
+			} else {
+				responses = append(responses, response{
+					href: href, status: http.StatusNotFound,
+				})
+			}
+		}
+	default:
+		writeError(w, http.StatusForbidden,
+			xml.Name{Space: nsDAV, Local: "supported-report"})
+		return
+	}
+	writeMultistatus(w, responses, req.Prop.names(), req.Prop == nil, false)
+}
+
+// decodeXMLBody decodes the request body into v. An empty body is not an error.
+func decodeXMLBody(r *http.Request, v any) error {
+	b, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+	return xml.Unmarshal(b, v)
+}
+
+// response is a single <D:response> of a multistatus.
+type response struct {
+	href string
+	// status is used instead of props if not zero.
+	status int
+	// props maps property names onto their inner XML.
+	props map[xml.Name]string
+	// hidden is the set of props only returned if explicitly requested.
+	hidden map[xml.Name]bool
+}
+
+func (h *Handler) rootResponse() response {
+	href := "<D:href>" + escape(h.hrefRoot()) + "</D:href>"
+	return response{
+		href: h.hrefRoot(),
+		props: map[xml.Name]string{
+			propResourceType:     "<D:collection/><D:principal/>",
+			propDisplayName:      "Todos",
+			propCurrentPrincipal: href,
+			propPrincipalURL:     href,
+			propCalendarHomeSet:  href,
+		},
+	}
+}
+
+func (h *Handler) listResponse(list string, todos []repository.Todo) response {
+	return response{
+		href: h.hrefList(list),
+		props: map[xml.Name]string{
+			propResourceType: "<D:collection/><C:calendar/>",
+			propDisplayName:  escape(list),
+			propOwner:        "<D:href>" + escape(h.hrefRoot()) + "</D:href>",
+			propPrivilegeSet: "<D:privilege><D:read/></D:privilege>" +
+				"<D:privilege><D:write/></D:privilege>",
+			propSupportedReportSet: "" +
+				"<D:supported-report><D:report><C:calendar-query/></D:report></D:supported-report>" +
+				"<D:supported-report><D:report><C:calendar-multiget/></D:report></D:supported-report>",
+			propSupportedCompSet: `<C:comp name="VTODO"/>`,
+			propGetCTag:          ctag(todos),
+		},
+	}
+}
+
+func (h *Handler) todoResponse(t repository.Todo, withData bool) response {
+	res := response{
+		href: h.hrefTodo(t),
+		props: map[xml.Name]string{
+			propResourceType:   "",
+			propGetETag:        escape(ETag(t)),
+			propGetContentType: contentTypeTodo,
+		},
+		hidden: map[xml.Name]bool{propCalendarData: true},
+	}
+	if withData {
+		var b strings.Builder
+		if err := ical.Write(&b, "", []repository.Todo{t}, time.Now()); err != nil {
+			panic(fmt.Errorf("writing ics: %w", err)) // strings.Builder never fails.
+		}
+		res.props[propCalendarData] = escape(b.String())
+	}
+	return res
+}
+
+func writeMultistatus(
+	w http.ResponseWriter, responses []response,
+	requested []xml.Name, allProps, namesOnly bool,
+) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<D:multistatus xmlns:D="` + nsDAV +
+		`" xmlns:C="` + nsCalDAV + `" xmlns:CS="` + nsCS + `">`)
+	for _, res := range responses {
+		b.WriteString("<D:response><D:href>" + escape(res.href) + "</D:href>")
+		if res.status != 0 {
+			writeStatus(&b, res.status)
+			b.WriteString("</D:response>")
+			continue
+		}
+		var found, missing []xml.Name
+		if allProps || namesOnly {
+			for name := range res.props {
+				if !res.hidden[name] {
+					found = append(found, name)
+				}
+			}
+			slices.SortFunc(found, func(a, b xml.Name) int {
+				return cmp.Or(
+					strings.Compare(a.Space, b.Space),
+					strings.Compare(a.Local, b.Local),
+				)
+			})
+		} else {
+			for _, name := range requested {
+				if _, ok := res.props[name]; ok {
+					found = append(found, name)
+				} else {
+					missing = append(missing, name)
+				}
+			}
+		}
+		if len(found) > 0 {
+			b.WriteString("<D:propstat><D:prop>")
+			for _, name := range found {
+				value := res.props[name]
+				if namesOnly {
+					value = ""
+				}
+				writeProp(&b, name, value)
+			}
+			b.WriteString("</D:prop>")
+			writeStatus(&b, http.StatusOK)
+			b.WriteString("</D:propstat>")
+		}
+		if len(missing) > 0 {
+			b.WriteString("<D:propstat><D:prop>")
+			for _, name := range missing {
+				writeProp(&b, name, "")
+			}
+			b.WriteString("</D:prop>")
+			writeStatus(&b, http.StatusNotFound)
+			b.WriteString("</D:propstat>")
+		}
+		b.WriteString("</D:response>")
+	}
+	b.WriteString("</D:multistatus>")
+
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+	_, _ = io.WriteString(w, b.String())
+}
+
+var prefixes = map[string]string{nsDAV: "D", nsCalDAV: "C", nsCS: "CS"}
+
+func writeProp(b *strings.Builder, name xml.Name, innerXML string) {
+	tag, attrs := name.Local, ""
+	if p, ok := prefixes[name.Space]; ok {
+		tag = p + ":" + name.Local
+	} else if name.Space != "" {
+		attrs = ` xmlns="` + escape(name.Space) + `"`
+	}
+	if innerXML == "" {
+		b.WriteString("<" + tag + attrs + "/>")
+		return
+	}
+	b.WriteString("<" + tag + attrs + ">" + innerXML + "</" + tag + ">")
+}
+
+func writeStatus(b *strings.Builder, code int) {
+	fmt.Fprintf(b, "<D:status>HTTP/1.1 %d %s</D:status>", code, http.StatusText(code))
+}
+
+// writeError writes a <D:error> body with the given precondition element.
+func writeError(w http.ResponseWriter, code int, precondition xml.Name) {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<D:error xmlns:D="` + nsDAV + `" xmlns:C="` + nsCalDAV + `">`)
+	writeProp(&b, precondition, "")
+	b.WriteString("</D:error>")
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(code)
+	_, _ = io.WriteString(w, b.String())
+}
+
+func escape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+func internalErr(w http.ResponseWriter, err error, msg string) {
+	slog.Error(msg, slog.Any("err", err))
+	const code = http.StatusInternalServerError
+	http.Error(w, http.StatusText(code), code)
+}
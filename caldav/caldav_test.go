@@ -0,0 +1,261 @@
+package caldav_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/caldav"
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// client is a minimal in-process CalDAV client.
+type client struct {
+	t    *testing.T
+	srv  *httptest.Server
+	repo *repository.Repository
+}
+
+func newClient(t *testing.T) *client {
+	t.Helper()
+	repo, err := repository.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+	mux := http.NewServeMux()
+	mux.Handle("/caldav/", caldav.NewHandler(repo, "/caldav/"))
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return &client{t: t, srv: srv, repo: repo}
+}
+
+// do sends a request to the escaped path and returns the response
+// with its body read.
+func (c *client) do(
+	method, path string, header map[string]string, body string,
+) (*http.Response, string) {
+	c.t.Helper()
+	req, err := http.NewRequest(method, c.srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	res, err := c.srv.Client().Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer res.Body.Close()
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return res, string(b)
+}
+
+func (c *client) expect(res *http.Response, body string, code int) {
+	c.t.Helper()
+	if res.StatusCode != code {
+		c.t.Fatalf("%s %s: status %d, want %d: %s",
+			res.Request.Method, res.Request.URL.Path, res.StatusCode, code, body)
+	}
+}
+
+func vtodo(uid, summary string) string {
+	return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:test\r\n" +
+		"BEGIN:VTODO\r\nUID:" + uid + "\r\nSUMMARY:" + summary + "\r\n" +
+		"STATUS:NEEDS-ACTION\r\nEND:VTODO\r\nEND:VCALENDAR\r\n"
+}
+
+func multiget(hrefs ...string) string {
+	var b strings.Builder
+	b.WriteString(`<C:calendar-multiget xmlns:D="DAV:" ` +
+		`xmlns:C="urn:ietf:params:xml:ns:caldav">` +
+		`<D:prop><D:getetag/><C:calendar-data/></D:prop>`)
+	for _, h := range hrefs {
+		b.WriteString("<D:href>" + h + "</D:href>")
+	}
+	b.WriteString("</C:calendar-multiget>")
+	return b.String()
+}
+
+func TestSync(t *testing.T) {
+	c := newClient(t)
+	const href = "/caldav/todos/task-1.ics"
+
+	res, body := c.do("PROPFIND", "/caldav/", map[string]string{"Depth": "1"}, "")
+	c.expect(res, body, http.StatusMultiStatus)
+	if !strings.Contains(body, "<D:href>/caldav/todos/</D:href>") {
+		t.Fatalf("default list missing: %s", body)
+	}
+
+	res, body = c.do("PUT", href,
+		map[string]string{"If-None-Match": "*"}, vtodo("task-1", "Buy milk"))
+	c.expect(res, body, http.StatusCreated)
+	etag := res.Header.Get("ETag")
+	if etag == "" {
+		t.Fatal("missing ETag")
+	}
+
+	res, body = c.do("GET", href, nil, "")
+	c.expect(res, body, http.StatusOK)
+	if res.Header.Get("ETag") != etag || !strings.Contains(body, "SUMMARY:Buy milk") {
+		t.Fatalf("unexpected GET: %q %s", res.Header.Get("ETag"), body)
+	}
+
+	res, body = c.do("PUT", href,
+		map[string]string{"If-Match": etag}, vtodo("task-1", "Buy oat milk"))
+	c.expect(res, body, http.StatusNoContent)
+	newETag := res.Header.Get("ETag")
+	if newETag == etag {
+		t.Fatal("ETag didn't change")
+	}
+
+	// Updates based on the previous state are rejected.
+	res, body = c.do("PUT", href,
+		map[string]string{"If-Match": etag}, vtodo("task-1", "Buy soy milk"))
+	c.expect(res, body, http.StatusPreconditionFailed)
+
+	res, body = c.do("REPORT", "/caldav/todos/", map[string]string{"Depth": "1"},
+		`<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">`+
+			`<D:prop><D:getetag/></D:prop><C:filter><C:comp-filter name="VCALENDAR">`+
+			`<C:comp-filter name="VTODO"/></C:comp-filter></C:filter></C:calendar-query>`)
+	c.expect(res, body, http.StatusMultiStatus)
+	if !strings.Contains(body, href) || !strings.Contains(body, strings.Trim(newETag, `"`)) {
+		t.Fatalf("calendar-query misses the todo: %s", body)
+	}
+
+	res, body = c.do("DELETE", href, map[string]string{"If-Match": etag}, "")
+	c.expect(res, body, http.StatusPreconditionFailed)
+	res, body = c.do("DELETE", href, map[string]string{"If-Match": newETag}, "")
+	c.expect(res, body, http.StatusNoContent)
+	res, body = c.do("GET", href, nil, "")
+	c.expect(res, body, http.StatusNotFound)
+}
+
+func TestHrefEscaping(t *testing.T) {
+	c := newClient(t)
+	const uid = "a b/c%d@example.com"
+	href := "/caldav/my%20list/" + url.PathEscape(uid) + ".ics"
+
+	res, body := c.do("PUT", href, nil, vtodo(uid, "Escaped"))
+	c.expect(res, body, http.StatusCreated)
+
+	res, body = c.do("PROPFIND", "/caldav/my%20list/", map[string]string{"Depth": "1"}, "")
+	c.expect(res, body, http.StatusMultiStatus)
+	if !strings.Contains(body, "<D:href>"+href+"</D:href>") {
+		t.Fatalf("PROPFIND misses %s: %s", href, body)
+	}
+
+	res, body = c.do("GET", href, nil, "")
+	c.expect(res, body, http.StatusOK)
+
+	res, body = c.do("REPORT", "/caldav/my%20list/", nil,
+		multiget(href, "/caldav/my%20list/missing.ics"))
+	c.expect(res, body, http.StatusMultiStatus)
+	if !strings.Contains(body, "SUMMARY:Escaped") {
+		t.Fatalf("multiget misses %s: %s", href, body)
+	}
+	if !strings.Contains(body, "404 Not Found") {
+		t.Fatalf("multiget doesn't report the missing href: %s", body)
+	}
+}
+
+func TestPutKeepsFieldsWithoutICalendarMapping(t *testing.T) {
+	c := newClient(t)
+	remind := time.Date(2030, 1, 2, 9, 0, 0, 0, time.UTC)
+	ids, err := c.repo.Import([]repository.Todo{
+		{Title: "Parent"},
+		{
+			Title:    "Child",
+			UID:      "child",
+			Parent:   "p",
+			Remind:   remind,
+			Projects: []string{"home"},
+			Meta:     map[string]string{"k": "v"},
+		},
+	}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, body := c.do("PUT", "/caldav/todos/child.ics", nil, vtodo("child", "Renamed"))
+	c.expect(res, body, http.StatusNoContent)
+
+	got, err := c.repo.Get(ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Title != "Renamed" {
+		t.Errorf("Title = %q", got.Title)
+	}
+	if !got.Remind.Equal(remind) || len(got.Projects) != 1 || got.Meta["k"] != "v" {
+		t.Errorf("fields without an iCalendar mapping were changed: %#v", got)
+	}
+
+	// Archived todos are hidden but stay archived when a client updates them.
+	if _, err := c.repo.Archive([]string{ids[1]}); err != nil {
+		t.Fatal(err)
+	}
+	res, body = c.do("GET", "/caldav/todos/child.ics", nil, "")
+	c.expect(res, body, http.StatusNotFound)
+	res, body = c.do("PUT", "/caldav/todos/child.ics", nil, vtodo("child", "Again"))
+	c.expect(res, body, http.StatusNoContent)
+	if got, err = c.repo.Get(ids[1]); err != nil {
+		t.Fatal(err)
+	} else if !got.Archived {
+		t.Error("PUT unarchived the todo")
+	}
+}
+
+func TestConcurrentCreate(t *testing.T) {
+	c := newClient(t)
+	const n = 8
+	codes := make(chan int, n)
+	var wg sync.WaitGroup
+	for range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, err := http.NewRequest("PUT", c.srv.URL+"/caldav/todos/race.ics",
+				strings.NewReader(vtodo("race", "Race")))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			req.Header.Set("If-None-Match", "*")
+			res, err := c.srv.Client().Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_ = res.Body.Close()
+			codes <- res.StatusCode
+		}()
+	}
+	wg.Wait()
+	close(codes)
+	created := 0
+	for code := range codes {
+		switch code {
+		case http.StatusCreated:
+			created++
+		case http.StatusPreconditionFailed:
+		default:
+			t.Errorf("unexpected status %d", code)
+		}
+	}
+	if created != 1 {
+		t.Errorf("%d PUTs created the todo, want 1", created)
+	}
+	if n := c.repo.Len(); n != 1 {
+		t.Errorf("repository has %d todos, want 1", n)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/config"
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// fatalf prints the error message and exits with code 1.
+func fatalf(format string, args ...any) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+
+// cmdBackup writes a backup archive of the data directory.
+// If the server is running the backup is requested from it,
+// otherwise the data directory is opened directly.
+func cmdBackup(conf *config.Config, args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	fOut := fs.String("o",
+		fmt.Sprintf("backup-%s.tar.gz", time.Now().Format("20060102T150405")),
+		"output file path")
+	fDataDir := fs.String("data-dir", conf.DataDir,
+		"data directory to use if the server isn't running")
+	_ = fs.Parse(args)
+
+	f, err := os.CreateTemp(filepath.Dir(*fOut), ".backup-*.tmp")
+	if err != nil {
+		fatalf("creating temporary file: %v", err)
+	}
+	defer os.Remove(f.Name()) // No-op after successful rename.
+
+	err = backupFromServer(f, conf.Host)
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		// The server isn't running.
+		err = backupFromDataDir(f, *fDataDir)
+	}
+	if err != nil {
+		_ = f.Close()
+		fatalf("creating backup: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		fatalf("closing temporary file: %v", err)
+	}
+	if err := os.Rename(f.Name(), *fOut); err != nil {
+		fatalf("renaming temporary file: %v", err)
+	}
+	fmt.Printf("backup written to %s\n", *fOut)
+}
+
+func backupFromServer(w io.Writer, host string) error {
+	h, port, err := net.SplitHostPort(host)
+	if err != nil {
+		return fmt.Errorf("parsing host: %w", err)
+	}
+	if ip := net.ParseIP(h); h == "" || (ip != nil && ip.IsUnspecified()) {
+		h = "localhost"
+	}
+	resp, err := http.Get("http://" + net.JoinHostPort(h, port) + "/backup/")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("server responded %d: %s", resp.StatusCode, msg)
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func backupFromDataDir(w io.Writer, dataDir string) error {
+	if dataDir == "" {
+		return errors.New("no data directory configured")
+	}
+	if _, err := os.Stat(dataDir); err != nil {
+		return fmt.Errorf("opening data directory: %w", err)
+	}
+	repo, err := repository.Open(dataDir)
+	if err != nil {
+		return err
+	}
+	defer repo.Close()
+	return repo.Backup(w, time.Now())
+}
+
+// cmdRestore restores a backup archive into an empty data directory.
+func cmdRestore(conf *config.Config, args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	fDataDir := fs.String("data-dir", conf.DataDir,
+		"empty data directory to restore into")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: restore [-data-dir dir] <archive>")
+		fs.PrintDefaults()
+	}
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *fDataDir == "" {
+		fatalf("no data directory configured")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fatalf("opening archive: %v", err)
+	}
+	defer f.Close()
+
+	m, err := repository.Restore(f, *fDataDir)
+	if err != nil {
+		fatalf("restoring backup: %v", err)
+	}
+	fmt.Printf("restored backup from %s (schema version %d) into %s\n",
+		m.Created.Format(time.RFC3339), m.SchemaVersion, *fDataDir)
+}
+
+// cmdMigrate migrates the store in the data directory to the current schema version.
+// The server migrates the store automatically when starting.
+func cmdMigrate(conf *config.Config, args []string) {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	fDataDir := fs.String("data-dir", conf.DataDir, "data directory")
+	fDryRun := fs.Bool("dry-run", false,
+		"only print the migration steps without writing anything")
+	_ = fs.Parse(args)
+	if *fDataDir == "" {
+		fatalf("no data directory configured")
+	}
+
+	steps, err := repository.Migrate(*fDataDir, *fDryRun)
+	if err != nil {
+		fatalf("migrating: %v", err)
+	}
+	if len(steps) < 1 {
+		fmt.Printf("store is up to date (schema version %d)\n", repository.SchemaVersion)
+		return
+	}
+	for _, s := range steps {
+		fmt.Printf("v%d -> v%d: %s\n", s.From, s.To, s.Description)
+	}
+	if *fDryRun {
+		fmt.Println("dry run, nothing was written")
+	}
+}
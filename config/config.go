@@ -9,6 +9,36 @@ import (
 
 type Config struct {
 	Host string `yaml:"host"`
+
+	// DataDir is the directory todos and the search index are persisted in.
+	// Todos are kept in memory only if empty.
+	DataDir string `yaml:"data-dir"`
+
+	Search struct {
+		// Fuzziness is the edit distance search terms are matched with,
+		// from 0 (exact prefix matches only) to 2.
+		Fuzziness int8 `yaml:"fuzziness"`
+
+		// BoostOpen ranks open todos above done ones.
+		BoostOpen bool `yaml:"boost-open"`
+
+		// BoostExactTitle ranks todos whose title equals the search term first.
+		BoostExactTitle bool `yaml:"boost-exact-title"`
+	} `yaml:"search"`
+
+	Reminders struct {
+		// SMTP delivers reminders by email, disabled if Addr is empty.
+		SMTP struct {
+			Addr     string   `yaml:"addr"` // host:port
+			Username string   `yaml:"username"`
+			Password string   `yaml:"password"`
+			From     string   `yaml:"from"`
+			To       []string `yaml:"to"`
+		} `yaml:"smtp"`
+
+		// Webhooks are URLs reminders are posted to as JSON.
+		Webhooks []string `yaml:"webhooks"`
+	} `yaml:"reminders"`
 }
 
 func MustLoad(filePath string) *Config {
@@ -0,0 +1,411 @@
+// Package ical reads and writes iCalendar (RFC 5545) VTODO components
+// mapping them onto repository.Todo.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// ContentType is the media type of iCalendar objects.
+const ContentType = "text/calendar; charset=utf-8"
+
+// ProdID identifies this application as the producer of calendar objects.
+const ProdID = "-//romshark//htmx-demo-todoapp//EN"
+
+// UIDSuffix is appended to todo IDs to form globally unique UIDs
+// for todos that don't have a UID.
+const UIDSuffix = "@htmx-demo-todoapp"
+
+// UID returns the UID of t.
+func UID(t repository.Todo) string {
+	if t.UID != "" {
+		return t.UID
+	}
+	return t.ID + UIDSuffix
+}
+
+const (
+	layoutDate        = "20060102"
+	layoutDateTime    = "20060102T150405"
+	layoutDateTimeUTC = "20060102T150405Z"
+)
+
+// maxLineLen is the maximum length of a content line in octets
+// excluding the line break.
+const maxLineLen = 75
+
+// Write writes a VCALENDAR object with one VTODO per todo to w.
+// calName is used as the calendar display name unless empty,
+// now is used as DTSTAMP.
+func Write(w io.Writer, calName string, todos []repository.Todo, now time.Time) error {
+	e := &encoder{w: bufio.NewWriter(w)}
+	e.line("BEGIN", "VCALENDAR")
+	e.line("VERSION", "2.0")
+	e.line("PRODID", ProdID)
+	if calName != "" {
+		e.line("X-WR-CALNAME", escapeText(calName))
+	}
+	for _, t := range todos {
+		writeTodo(e, t, now)
+	}
+	e.line("END", "VCALENDAR")
+	if e.err != nil {
+		return e.err
+	}
+	return e.w.Flush()
+}
+
+func writeTodo(e *encoder, t repository.Todo, now time.Time) {
+	e.line("BEGIN", "VTODO")
+	e.line("UID", escapeText(UID(t)))
+	e.line("DTSTAMP", now.UTC().Format(layoutDateTimeUTC))
+	if !t.Created.IsZero() {
+		e.line("CREATED", t.Created.UTC().Format(layoutDateTimeUTC))
+	}
+	e.line("SUMMARY", escapeText(t.Title))
+	if t.Done {
+		e.line("STATUS", "COMPLETED")
+		if !t.Completed.IsZero() {
+			e.line("COMPLETED", t.Completed.UTC().Format(layoutDateTimeUTC))
+		}
+	} else {
+		e.line("STATUS", "NEEDS-ACTION")
+	}
+	if p := priorityToICal(t.Priority); p != 0 {
+		e.line("PRIORITY", strconv.Itoa(p))
+	}
+	if !t.Due.IsZero() {
+		if loc, err := time.LoadLocation(t.TimeZone); isMidnight(t.Due) {
+			// Due dates without a time of day are written as DATE values.
+			e.line("DUE;VALUE=DATE", t.Due.Format(layoutDate))
+		} else if t.TimeZone != "" && err == nil {
+			e.line("DUE;TZID="+t.TimeZone, t.Due.In(loc).Format(layoutDateTime))
+		} else {
+			e.line("DUE", t.Due.UTC().Format(layoutDateTimeUTC))
+		}
+	}
+	if t.Recurrence != "" {
+		e.line("RRULE", t.Recurrence)
+	}
+	if len(t.Contexts) > 0 {
+		c := make([]string, len(t.Contexts))
+		for i := range t.Contexts {
+			c[i] = escapeText(t.Contexts[i])
+		}
+		e.line("CATEGORIES", strings.Join(c, ","))
+	}
+	e.line("END", "VTODO")
+}
+
+func isMidnight(t time.Time) bool {
+	h, m, s := t.Clock()
+	return h == 0 && m == 0 && s == 0 && t.Nanosecond() == 0
+}
+
+// priorityToICal maps 'A'-'H' onto 1-8 and everything below onto 9.
+// PriorityNone maps onto 0 (undefined).
+func priorityToICal(p repository.Priority) int {
+	switch {
+	case p == repository.PriorityNone:
+		return 0
+	case p <= 'H':
+		return int(p-'A') + 1
+	}
+	return 9
+}
+
+// priorityFromICal is the inverse of priorityToICal.
+func priorityFromICal(p int) repository.Priority {
+	if p < 1 || p > 9 {
+		return repository.PriorityNone
+	}
+	return repository.Priority('A' + p - 1)
+}
+
+type encoder struct {
+	w   *bufio.Writer
+	err error
+}
+
+// line writes a folded content line.
+func (e *encoder) line(name, value string) {
+	if e.err != nil {
+		return
+	}
+	_, e.err = e.w.WriteString(fold(name + ":" + value))
+}
+
+// fold splits l into lines of at most maxLineLen octets each terminated by CRLF.
+// Continuation lines start with a single space. Multi-octet UTF-8 sequences
+// are never split.
+func fold(l string) string {
+	var b strings.Builder
+	limit := maxLineLen
+	for len(l) > limit {
+		i := limit
+		for i > 0 && !utf8.RuneStart(l[i]) {
+			i--
+		}
+		b.WriteString(l[:i])
+		b.WriteString("\r\n ")
+		l = l[i:]
+		limit = maxLineLen - 1 // Account for the leading space.
+	}
+	b.WriteString(l)
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+var textEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	";", `\;`,
+	",", `\,`,
+	"\r\n", `\n`,
+	"\n", `\n`,
+)
+
+func escapeText(s string) string { return textEscaper.Replace(s) }
+
+func unescapeText(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n', 'N':
+			b.WriteByte('\n')
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String()
+}
+
+// splitText splits a multi-value TEXT property at unescaped commas
+// and unescapes the values.
+func splitText(s string) []string {
+	var values []string
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case ',':
+			values = append(values, unescapeText(s[start:i]))
+			start = i + 1
+		}
+	}
+	return append(values, unescapeText(s[start:]))
+}
+
+// property is a single unfolded content line.
+type property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// Read parses all VTODO components of all VCALENDAR objects in r.
+// Floating date-times and dates are interpreted in loc.
+// Components other than VTODO are ignored.
+func Read(r io.Reader, loc *time.Location) ([]repository.Todo, error) {
+	props, err := readProperties(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		todos  []repository.Todo
+		inTodo bool
+		depth  int // Depth of nested components within a VTODO (e.g. VALARM).
+		t      repository.Todo
+	)
+	for _, p := range props {
+		switch {
+		case p.Name == "BEGIN" && strings.EqualFold(p.Value, "VTODO") && !inTodo:
+			inTodo, t = true, repository.Todo{}
+		case !inTodo:
+			continue
+		case p.Name == "BEGIN":
+			depth++
+		case p.Name == "END" && depth > 0:
+			depth--
+		case p.Name == "END":
+			inTodo = false
+			todos = append(todos, t)
+		case depth > 0:
+			continue
+		default:
+			if err := setProperty(&t, p, loc); err != nil {
+				return nil, fmt.Errorf("VTODO %d: %w", len(todos)+1, err)
+			}
+		}
+	}
+	if inTodo {
+		return nil, fmt.Errorf("VTODO %d: missing END:VTODO", len(todos)+1)
+	}
+	return todos, nil
+}
+
+func setProperty(t *repository.Todo, p property, loc *time.Location) (err error) {
+	switch p.Name {
+	case "UID":
+		t.UID = unescapeText(p.Value)
+	case "SUMMARY":
+		t.Title = unescapeText(p.Value)
+	case "STATUS":
+		t.Done = strings.EqualFold(p.Value, "COMPLETED")
+	case "PRIORITY":
+		v, err := strconv.Atoi(p.Value)
+		if err != nil {
+			return fmt.Errorf("invalid PRIORITY: %q", p.Value)
+		}
+		t.Priority = priorityFromICal(v)
+	case "CREATED":
+		t.Created, err = parseTime(p, loc)
+	case "COMPLETED":
+		t.Completed, err = parseTime(p, loc)
+		t.Done = true
+	case "DUE":
+		t.Due, err = parseTime(p, loc)
+		t.TimeZone = p.Params["TZID"]
+	case "RRULE":
+		r, err := repository.ParseRecurrence(p.Value)
+		if err != nil {
+			return fmt.Errorf("RRULE: %w", err)
+		}
+		t.Recurrence = r.String()
+	case "CATEGORIES":
+		for _, c := range splitText(p.Value) {
+			if c != "" {
+				t.Contexts = append(t.Contexts, c)
+			}
+		}
+	}
+	return err
+}
+
+// parseTime parses DATE and DATE-TIME values.
+// Values with a TZID parameter are interpreted in the given time zone.
+func parseTime(p property, loc *time.Location) (time.Time, error) {
+	if tzid, ok := p.Params["TZID"]; ok {
+		l, err := time.LoadLocation(tzid)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("%s: unknown TZID: %q", p.Name, tzid)
+		}
+		loc = l
+	}
+	var (
+		t   time.Time
+		err error
+	)
+	switch {
+	case strings.EqualFold(p.Params["VALUE"], "DATE") || len(p.Value) == len(layoutDate):
+		t, err = time.ParseInLocation(layoutDate, p.Value, loc)
+	case strings.HasSuffix(p.Value, "Z"):
+		t, err = time.Parse(layoutDateTimeUTC, p.Value)
+	default:
+		t, err = time.ParseInLocation(layoutDateTime, p.Value, loc)
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s: invalid date: %q", p.Name, p.Value)
+	}
+	return t, nil
+}
+
+// readProperties unfolds and parses all content lines of r.
+func readProperties(r io.Reader) ([]property, error) {
+	var (
+		props []property
+		lines []string
+	)
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1024*1024)
+	for sc.Scan() {
+		l := strings.TrimRight(sc.Text(), "\r")
+		if len(l) > 0 && (l[0] == ' ' || l[0] == '\t') {
+			if len(lines) == 0 {
+				return nil, fmt.Errorf("unexpected continuation line")
+			}
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	for i, l := range lines {
+		if l == "" {
+			continue
+		}
+		p, err := parseProperty(l)
+		if err != nil {
+			return nil, fmt.Errorf("content line %d: %w", i+1, err)
+		}
+		props = append(props, p)
+	}
+	return props, nil
+}
+
+// parseProperty parses an unfolded content line:
+//
+//	name *(";" param) ":" value
+func parseProperty(l string) (property, error) {
+	var p property
+	i := strings.IndexAny(l, ";:")
+	if i < 1 {
+		return p, fmt.Errorf("missing property name")
+	}
+	p.Name = strings.ToUpper(l[:i])
+	for l[i] == ';' {
+		l = l[i+1:]
+		eq := strings.IndexByte(l, '=')
+		if eq < 1 {
+			return p, fmt.Errorf("%s: malformed parameter", p.Name)
+		}
+		name := strings.ToUpper(l[:eq])
+		l = l[eq+1:]
+		var value string
+		if strings.HasPrefix(l, `"`) {
+			end := strings.IndexByte(l[1:], '"')
+			if end < 0 {
+				return p, fmt.Errorf("%s: unterminated quoted parameter", p.Name)
+			}
+			value, l = l[1:end+1], l[end+2:]
+			i = 0
+		} else {
+			i = strings.IndexAny(l, ";:")
+			if i < 0 {
+				return p, fmt.Errorf("%s: missing value", p.Name)
+			}
+			value, l, i = l[:i], l[i:], 0
+		}
+		if p.Params == nil {
+			p.Params = map[string]string{}
+		}
+		p.Params[name] = value
+		if l == "" {
+			return p, fmt.Errorf("%s: missing value", p.Name)
+		}
+	}
+	if l[i] != ':' {
+		return p, fmt.Errorf("%s: missing value", p.Name)
+	}
+	p.Value = l[i+1:]
+	return p, nil
+}
@@ -0,0 +1,182 @@
+package ical_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata" // Time zones of the TZID tests.
+	"unicode/utf8"
+
+	"github.com/romshark/htmx-demo-todoapp/ical"
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+var now = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func mustLoad(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+func TestRoundTrip(t *testing.T) {
+	kyiv := mustLoad(t, "Europe/Kyiv")
+	for _, td := range []struct {
+		name string
+		todo repository.Todo
+	}{
+		{
+			name: "escaping",
+			todo: repository.Todo{
+				UID:   "a;b,c@example.com",
+				Title: "Buy milk, eggs; bread\\butter\nand cheese",
+			},
+		},
+		{
+			name: "multi-byte folding",
+			todo: repository.Todo{
+				UID:   "long@example.com",
+				Title: strings.Repeat("Купити молоко 🥛 ", 10),
+			},
+		},
+		{
+			name: "categories",
+			todo: repository.Todo{
+				UID:      "tags@example.com",
+				Title:    "Tags",
+				Contexts: []string{"home", "a,b", "c;d"},
+			},
+		},
+		{
+			name: "done",
+			todo: repository.Todo{
+				UID:       "done@example.com",
+				Title:     "Done",
+				Done:      true,
+				Priority:  'A',
+				Created:   time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC),
+				Completed: time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "due date",
+			todo: repository.Todo{
+				UID:   "date@example.com",
+				Title: "Taxes",
+				Due:   time.Date(2024, 4, 30, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "due UTC",
+			todo: repository.Todo{
+				UID:   "utc@example.com",
+				Title: "Call",
+				Due:   time.Date(2024, 4, 30, 14, 15, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "due TZID",
+			todo: repository.Todo{
+				UID:        "tzid@example.com",
+				Title:      "Standup",
+				Due:        time.Date(2024, 7, 1, 9, 0, 0, 0, kyiv),
+				TimeZone:   "Europe/Kyiv",
+				Recurrence: "FREQ=WEEKLY;BYDAY=MO",
+			},
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			var b bytes.Buffer
+			err := ical.Write(&b, "Test", []repository.Todo{td.todo}, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkLines(t, b.String())
+
+			todos, err := ical.Read(&b, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(todos) != 1 {
+				t.Fatalf("read %d todos, want 1", len(todos))
+			}
+			got, want := todos[0], td.todo
+			if !got.Due.Equal(want.Due) {
+				t.Errorf("Due = %v, want %v", got.Due, want.Due)
+			}
+			got.Due, want.Due = time.Time{}, time.Time{}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("read\n%#v\nwant\n%#v", got, want)
+			}
+		})
+	}
+}
+
+// checkLines checks that all content lines of s end with CRLF
+// and are at most 75 octets long without splitting UTF-8 sequences.
+func checkLines(t *testing.T, s string) {
+	t.Helper()
+	if !strings.HasSuffix(s, "\r\n") {
+		t.Fatalf("missing final CRLF")
+	}
+	for i, l := range strings.Split(strings.TrimSuffix(s, "\r\n"), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line %d has %d octets: %q", i+1, len(l), l)
+		}
+		if !utf8.ValidString(l) {
+			t.Errorf("line %d splits a UTF-8 sequence: %q", i+1, l)
+		}
+		if strings.ContainsAny(l, "\r\n") {
+			t.Errorf("line %d contains a bare line break: %q", i+1, l)
+		}
+	}
+}
+
+func TestReadUnfold(t *testing.T) {
+	const in = "BEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VTODO\r\n" +
+		"UID:x@example.com\r\n" +
+		"SUMMARY:Water the \r\n" +
+		" plants\\, then rest\r\n" +
+		"DUE;TZID=America/New_York:20240701T170000\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"SUMMARY:ignored\r\n" +
+		"END:VALARM\r\n" +
+		"END:VTODO\r\n" +
+		"END:VCALENDAR\r\n"
+	todos, err := ical.Read(strings.NewReader(in), time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 1 {
+		t.Fatalf("read %d todos, want 1", len(todos))
+	}
+	if want := "Water the plants, then rest"; todos[0].Title != want {
+		t.Errorf("Title = %q, want %q", todos[0].Title, want)
+	}
+	ny := mustLoad(t, "America/New_York")
+	if want := time.Date(2024, 7, 1, 17, 0, 0, 0, ny); !todos[0].Due.Equal(want) {
+		t.Errorf("Due = %v, want %v", todos[0].Due, want)
+	}
+	if todos[0].TimeZone != "America/New_York" {
+		t.Errorf("TimeZone = %q", todos[0].TimeZone)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	for _, in := range []string{
+		"BEGIN:VTODO\r\nSUMMARY:unterminated\r\n",
+		"BEGIN:VTODO\r\nDUE;TZID=Nowhere/City:20240701T170000\r\nEND:VTODO\r\n",
+		"BEGIN:VTODO\r\nPRIORITY:high\r\nEND:VTODO\r\n",
+	} {
+		if _, err := ical.Read(strings.NewReader(in), time.UTC); err == nil {
+			t.Errorf("Read(%q): expected error", in)
+		}
+	}
+}
@@ -1,18 +1,27 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"log/slog"
+	"net"
 	"net/http"
+	"net/smtp"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/romshark/httpsim"
 	httpsimconf "github.com/romshark/httpsim/config"
 
 	"github.com/romshark/htmx-demo-todoapp/config"
+	"github.com/romshark/htmx-demo-todoapp/reminder"
 	"github.com/romshark/htmx-demo-todoapp/repository"
 	"github.com/romshark/htmx-demo-todoapp/server"
+	"github.com/romshark/htmx-demo-todoapp/views"
+	"github.com/romshark/htmx-demo-todoapp/webhook"
 )
 
 func panicOnErr(err error) {
@@ -23,6 +32,20 @@ func panicOnErr(err error) {
 
 func main() {
 	conf := config.MustLoad("config.yml")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backup":
+			cmdBackup(conf, os.Args[2:])
+			return
+		case "restore":
+			cmdRestore(conf, os.Args[2:])
+			return
+		case "migrate":
+			cmdMigrate(conf, os.Args[2:])
+			return
+		}
+	}
+
 	fHTTPSimConfig := flag.String(
 		"httpsim-conf",
 		"httpsim.yml",
@@ -30,22 +53,40 @@ func main() {
 	)
 	flag.Parse()
 
-	repo, err := repository.NewRepository()
+	repo, err := openRepository(conf.DataDir)
 	panicOnErr(err)
+	defer func() {
+		if err := repo.Close(); err != nil {
+			slog.Error("closing repository", slog.Any("err", err))
+		}
+	}()
+	panicOnErr(repo.SetFuzziness(int(conf.Search.Fuzziness)))
+	repo.SetBoosts(repository.Boosts{
+		Open:       conf.Search.BoostOpen,
+		ExactTitle: conf.Search.BoostExactTitle,
+	})
 
-	// Add some default demo todos.
-	_, err = repo.Add("Buy milk", false, time.Now())
-	panicOnErr(err)
-	_, err = repo.Add("Wash the car", false, time.Now())
-	panicOnErr(err)
-	_, err = repo.Add("Feed the cat", true, time.Now())
-	panicOnErr(err)
-	_, err = repo.Add("Buy more cat food", false, time.Now())
+	if repo.Len() < 1 {
+		// Add some default demo todos.
+		_, err = repo.Add("Buy milk", false, time.Now())
+		panicOnErr(err)
+		_, err = repo.Add("Wash the car", false, time.Now())
+		panicOnErr(err)
+		_, err = repo.Add("Feed the cat", true, time.Now())
+		panicOnErr(err)
+		_, err = repo.Add("Buy more cat food", false, time.Now())
+		panicOnErr(err)
+		_, err = repo.Add("Make search faster", false, time.Now())
+		panicOnErr(err)
+	}
+
+	hooks, err := webhook.Open(conf.DataDir, reminder.RealClock{}, &http.Client{})
 	panicOnErr(err)
-	_, err = repo.Add("Make search faster", false, time.Now())
+
+	savedViews, err := views.Open(conf.DataDir)
 	panicOnErr(err)
 
-	s := server.New(repo)
+	s := server.New(repo, hooks, savedViews)
 
 	// Use httpsim middleware for simulating error responses and delays.
 	httpsimConf, err := httpsimconf.LoadFile(*fHTTPSimConfig)
@@ -56,10 +97,60 @@ func main() {
 		server.WithLog(s), *httpsimConf, httpsim.DefaultSleep, httpsim.DefaultRand,
 	)
 
+	// Shut down gracefully on interrupt to close the search index properly.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	scheduler := reminder.NewScheduler(
+		repo, reminder.RealClock{}, reminderNotifiers(conf, s)...,
+	)
+	go scheduler.Run(ctx)
+	go hooks.Run(ctx, repo)
+
+	httpServer := &http.Server{
+		Addr:    conf.Host,
+		Handler: withHTTPSim,
+		// Cancel long-lived requests such as event streams on shutdown.
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+	go func() {
+		<-ctx.Done()
+		if err := httpServer.Shutdown(context.Background()); err != nil {
+			slog.Error("shutting down http server", slog.Any("err", err))
+		}
+	}()
+
 	slog.Info("listening", slog.String("host", conf.Host))
-	if err := http.ListenAndServe(conf.Host, withHTTPSim); err != nil {
+	if err := httpServer.ListenAndServe(); err != nil {
 		if !errors.Is(err, http.ErrServerClosed) {
 			slog.Error("http server error", slog.Any("err", err))
 		}
 	}
 }
+
+// openRepository opens the repository in dataDir or
+// creates an in-memory repository if dataDir is empty.
+func openRepository(dataDir string) (*repository.Repository, error) {
+	if dataDir == "" {
+		return repository.NewRepository()
+	}
+	return repository.Open(dataDir)
+}
+
+// reminderNotifiers returns the browser notifier of s
+// and the notifiers enabled in conf.
+func reminderNotifiers(conf *config.Config, s *server.Server) []reminder.Notifier {
+	notifiers := []reminder.Notifier{s.Notifier()}
+	if c := conf.Reminders.SMTP; c.Addr != "" {
+		n := &reminder.SMTPNotifier{Addr: c.Addr, From: c.From, To: c.To}
+		if c.Username != "" {
+			host, _, _ := net.SplitHostPort(c.Addr)
+			n.Auth = smtp.PlainAuth("", c.Username, c.Password, host)
+		}
+		notifiers = append(notifiers, n)
+	}
+	for _, url := range conf.Reminders.Webhooks {
+		notifiers = append(notifiers, &reminder.WebhookNotifier{URL: url})
+	}
+	return notifiers
+}
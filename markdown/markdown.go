@@ -0,0 +1,153 @@
+// Package markdown reads and writes todos as GitHub-flavored Markdown task lists.
+//
+//	## list name
+//
+//	- [ ] Buy milk
+//	- [x] Clean up
+//	  - [x] Kitchen
+package markdown
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// ContentType is the media type of Markdown documents.
+const ContentType = "text/markdown; charset=utf-8"
+
+// indent is the indentation of subtasks per nesting level.
+const indent = "  "
+
+// Write writes every list as a level 2 heading followed by its task list.
+// todos must be in the desired order from top to bottom, subtasks are nested below their parents
+// while subtasks of parents that aren't in todos are written as top-level tasks.
+func Write(w io.Writer, lists []string, todos []repository.Todo) error {
+	bw := bufio.NewWriter(w)
+	for i, list := range lists {
+		if i > 0 {
+			_, _ = bw.WriteString("\n")
+		}
+		_, _ = bw.WriteString("## " + list + "\n\n")
+
+		inList := make(map[string]bool)
+		for _, t := range todos {
+			if t.List == list {
+				inList[t.ID] = true
+			}
+		}
+		children := make(map[string][]repository.Todo)
+		var roots []repository.Todo
+		for _, t := range todos {
+			switch {
+			case t.List != list:
+			case t.Parent != "" && inList[t.Parent]:
+				children[t.Parent] = append(children[t.Parent], t)
+			default:
+				roots = append(roots, t)
+			}
+		}
+		for _, t := range roots {
+			writeTree(bw, t, children, 0)
+		}
+	}
+	return bw.Flush()
+}
+
+func writeTree(
+	w *bufio.Writer, t repository.Todo,
+	children map[string][]repository.Todo, depth int,
+) {
+	checkbox := "[ ]"
+	if t.Done {
+		checkbox = "[x]"
+	}
+	_, _ = w.WriteString(strings.Repeat(indent, depth) + "- " + checkbox + " " +
+		strings.ReplaceAll(t.Title, "\n", " ") + "\n")
+	for _, c := range children[t.ID] {
+		writeTree(w, c, children, depth+1)
+	}
+}
+
+// Read parses all task list items in r in document order.
+// Headings set the list of the following tasks, lines that aren't
+// task list items are ignored. Subtasks reference their parents through
+// temporary IDs as expected by repository.Repository.Import.
+func Read(r io.Reader) ([]repository.Todo, error) {
+	type level struct {
+		indent int
+		id     string
+	}
+	var (
+		todos []repository.Todo
+		stack []level
+		list  string
+	)
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		l := strings.ReplaceAll(sc.Text(), "\t", "    ")
+		trimmed := strings.TrimLeft(l, " ")
+
+		if h, ok := cutHeading(trimmed); ok {
+			list, stack = h, stack[:0]
+			continue
+		}
+		title, done, ok := cutTaskItem(trimmed)
+		if !ok {
+			continue
+		}
+		if title == "" {
+			return nil, fmt.Errorf("line %d: empty task", line)
+		}
+
+		ind := len(l) - len(trimmed)
+		for len(stack) > 0 && stack[len(stack)-1].indent >= ind {
+			stack = stack[:len(stack)-1]
+		}
+		t := repository.Todo{
+			ID:    strconv.Itoa(len(todos) + 1),
+			List:  list,
+			Title: title,
+			Done:  done,
+		}
+		if len(stack) > 0 {
+			t.Parent = stack[len(stack)-1].id
+		}
+		stack = append(stack, level{indent: ind, id: t.ID})
+		todos = append(todos, t)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+func cutHeading(l string) (text string, ok bool) {
+	h := strings.TrimLeft(l, "#")
+	if n := len(l) - len(h); n < 1 || n > 6 || !strings.HasPrefix(h, " ") {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimRight(strings.TrimSpace(h), "#")), true
+}
+
+func cutTaskItem(l string) (title string, done, ok bool) {
+	if len(l) < 2 || !strings.ContainsRune("-*+", rune(l[0])) || l[1] != ' ' {
+		return "", false, false
+	}
+	l = strings.TrimLeft(l[2:], " ")
+	if len(l) < 3 || l[0] != '[' || l[2] != ']' || (len(l) > 3 && l[3] != ' ') {
+		return "", false, false
+	}
+	switch l[1] {
+	case ' ':
+	case 'x', 'X':
+		done = true
+	default:
+		return "", false, false
+	}
+	return strings.TrimSpace(l[3:]), done, true
+}
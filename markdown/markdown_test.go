@@ -0,0 +1,102 @@
+package markdown_test
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/romshark/htmx-demo-todoapp/markdown"
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+const document = `## todos
+
+- [ ] Buy milk
+- [x] Clean up
+  - [x] Kitchen
+  - [ ] Bathroom
+    - [ ] Mirror
+- [ ] Call mom
+
+## work
+
+- [X] Write report
+`
+
+func TestRead(t *testing.T) {
+	todos, err := markdown.Read(strings.NewReader(
+		"# Notes\n\nSome text.\n\n" + strings.ReplaceAll(document, "    - ", "\t- "),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []repository.Todo{
+		{ID: "1", List: "todos", Title: "Buy milk"},
+		{ID: "2", List: "todos", Title: "Clean up", Done: true},
+		{ID: "3", List: "todos", Title: "Kitchen", Done: true, Parent: "2"},
+		{ID: "4", List: "todos", Title: "Bathroom", Parent: "2"},
+		{ID: "5", List: "todos", Title: "Mirror", Parent: "4"},
+		{ID: "6", List: "todos", Title: "Call mom"},
+		{ID: "7", List: "work", Title: "Write report", Done: true},
+	}
+	if !reflect.DeepEqual(todos, want) {
+		t.Errorf("got %#v\nwant %#v", todos, want)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	todos, err := markdown.Read(strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := markdown.Write(&b, []string{"todos", "work"}, todos); err != nil {
+		t.Fatal(err)
+	}
+	// [X] is written as [x].
+	if want := strings.Replace(document, "[X]", "[x]", 1); b.String() != want {
+		t.Errorf("written document differs:\n%s\nwant:\n%s", b.String(), want)
+	}
+	again, err := markdown.Read(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, todos) {
+		t.Errorf("round trip changed todos:\n got %#v\nwant %#v", again, todos)
+	}
+}
+
+func TestWriteOrphans(t *testing.T) {
+	// Subtasks of parents in other lists or not written are top-level tasks.
+	var b bytes.Buffer
+	err := markdown.Write(&b, []string{"a", "b"}, []repository.Todo{
+		{ID: "1", List: "a", Title: "Parent"},
+		{ID: "2", List: "b", Title: "Other list", Parent: "1"},
+		{ID: "3", List: "b", Title: "Archived parent", Parent: "4", Done: true},
+		{ID: "5", List: "a", Title: "Multi\nline", Parent: "1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "## a\n\n- [ ] Parent\n  - [ ] Multi line\n\n" +
+		"## b\n\n- [ ] Other list\n- [x] Archived parent\n"
+	if b.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	if _, err := markdown.Read(strings.NewReader("## a\n\n- [ ] A\n- [ ]\n")); err == nil ||
+		err.Error() != "line 4: empty task" {
+		t.Errorf("empty task: %v", err)
+	}
+	// Lines that aren't task list items are ignored.
+	todos, err := markdown.Read(strings.NewReader("- item\n- [y] no\n-[ ] no\n* [ ] Yes\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(todos) != 1 || todos[0].Title != "Yes" {
+		t.Errorf("got %#v", todos)
+	}
+}
@@ -0,0 +1,321 @@
+// Package quickadd parses the natural language input of the "New Todo" form.
+//
+// The following tokens are extracted from the title:
+//
+//	today, tomorrow, monday...sunday              due date
+//	next fri, on friday, on mon, on 2024-05-31    due date
+//	in 3 days, in 2 weeks, in 1 month             due date
+//	9am, 9:30pm, 14:00, noon, at 9am              due time
+//	!high, !medium, !low, !A...!Z                 priority
+//	#home                                         context tag
+//	+garden                                       project tag
+//	list:groceries                                list
+//
+// Weekday abbreviations are only recognized after "on" and "next"
+// since they're common words ("Buy sun cream").
+// A due time without a date is due today, or tomorrow if the time has passed.
+// Tokens are kept in the title literally when escaped with a backslash
+// (\#1, \tomorrow) or when quoted ("Today Show"). Quotes around text
+// without tokens are kept (Say "hi" to mom).
+package quickadd
+
+import (
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// Result is a parsed quick-add input.
+type Result struct {
+	Title    string
+	Due      time.Time // Zero if no date or time was given.
+	Priority repository.Priority
+	Contexts []string
+	Projects []string
+	List     string // Empty for the default list.
+}
+
+// Todo returns the todo described by r.
+func (r Result) Todo() repository.Todo {
+	return repository.Todo{
+		List:     r.List,
+		Title:    r.Title,
+		Due:      r.Due,
+		Priority: r.Priority,
+		Contexts: r.Contexts,
+		Projects: r.Projects,
+	}
+}
+
+// token is a whitespace separated word of the input.
+type token struct {
+	text string
+	// literal is true for escaped and quoted tokens.
+	literal bool
+	// quoted is true if text was quoted, it's unquoted.
+	quoted bool
+}
+
+var priorities = map[string]repository.Priority{
+	"high":   'A',
+	"medium": 'B',
+	"med":    'B',
+	"low":    'C',
+}
+
+var (
+	regexpTime12 = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)$`)
+	regexpTime24 = regexp.MustCompile(`^(\d{1,2}):(\d{2})$`)
+)
+
+// Parse parses input relative to now. Dates are computed in the location of now.
+func Parse(input string, now time.Time) Result {
+	var r Result
+	tokens := tokenize(input)
+	var title []string
+	var date time.Time
+	hasTime := false
+	var hh, mm int
+
+	for i := 0; i < len(tokens); i++ {
+		t := tokens[i]
+		if t.literal {
+			text := t.text
+			if t.quoted && !hasTokens(text, now) {
+				text = `"` + text + `"`
+			}
+			title = append(title, text)
+			continue
+		}
+		lower := strings.ToLower(t.text)
+
+		// Prepositions are consumed together with the date or time they precede.
+		if lower == "on" || lower == "at" || lower == "next" || lower == "by" {
+			if i+1 < len(tokens) && !tokens[i+1].literal {
+				next := strings.ToLower(tokens[i+1].text)
+				if lower == "at" && !hasTime {
+					if h, m, ok := parseTime(next); ok {
+						hh, mm, hasTime = h, m, true
+						i++
+						continue
+					}
+				}
+				if lower != "at" && date.IsZero() {
+					abbreviated := lower == "on" || lower == "next"
+					if d, ok := parseDate(next, now, abbreviated); ok {
+						date = d
+						i++
+						continue
+					}
+				}
+			}
+			title = append(title, t.text)
+			continue
+		}
+
+		if date.IsZero() {
+			if d, ok := parseDate(lower, now, false); ok {
+				date = d
+				continue
+			}
+			if lower == "in" && i+2 < len(tokens) &&
+				!tokens[i+1].literal && !tokens[i+2].literal {
+				if d, ok := parseRelative(
+					tokens[i+1].text, strings.ToLower(tokens[i+2].text), now,
+				); ok {
+					date = d
+					i += 2
+					continue
+				}
+			}
+		}
+		if !hasTime {
+			if h, m, ok := parseTime(lower); ok {
+				hh, mm, hasTime = h, m, true
+				continue
+			}
+		}
+
+		switch {
+		case r.Priority == repository.PriorityNone && strings.HasPrefix(lower, "!"):
+			if p, ok := parsePriority(t.text[1:]); ok {
+				r.Priority = p
+				continue
+			}
+		case strings.HasPrefix(t.text, "#") && isTag(t.text[1:]):
+			if !slices.Contains(r.Contexts, t.text[1:]) {
+				r.Contexts = append(r.Contexts, t.text[1:])
+			}
+			continue
+		case strings.HasPrefix(t.text, "+") && isTag(t.text[1:]):
+			if !slices.Contains(r.Projects, t.text[1:]) {
+				r.Projects = append(r.Projects, t.text[1:])
+			}
+			continue
+		case r.List == "" && strings.HasPrefix(lower, "list:") && isTag(t.text[5:]):
+			r.List = t.text[5:]
+			continue
+		}
+		title = append(title, t.text)
+	}
+
+	r.Title = strings.Join(title, " ")
+	switch {
+	case hasTime && date.IsZero():
+		y, m, d := now.Date()
+		r.Due = time.Date(y, m, d, hh, mm, 0, 0, now.Location())
+		if !r.Due.After(now) {
+			r.Due = time.Date(y, m, d+1, hh, mm, 0, 0, now.Location())
+		}
+	case hasTime:
+		y, m, d := date.Date()
+		r.Due = time.Date(y, m, d, hh, mm, 0, 0, now.Location())
+	default:
+		r.Due = date
+	}
+	return r
+}
+
+// tokenize splits s by whitespace. Quoted strings are a single literal token,
+// a backslash makes the rest of the word literal.
+func tokenize(s string) []token {
+	var tokens []token
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimLeftFunc(s, unicode.IsSpace) {
+		switch {
+		case s[0] == '"':
+			if end := strings.IndexByte(s[1:], '"'); end > 0 {
+				tokens = append(tokens, token{text: s[1 : end+1], literal: true, quoted: true})
+				s = s[end+2:]
+				continue
+			}
+		case s[0] == '\\' && len(s) > 1 && !unicode.IsSpace(rune(s[1])):
+			end := strings.IndexFunc(s, unicode.IsSpace)
+			if end < 0 {
+				end = len(s)
+			}
+			tokens = append(tokens, token{text: s[1:end], literal: true})
+			s = s[end:]
+			continue
+		}
+		end := strings.IndexFunc(s, unicode.IsSpace)
+		if end < 0 {
+			end = len(s)
+		}
+		tokens = append(tokens, token{text: s[:end]})
+		s = s[end:]
+	}
+	return tokens
+}
+
+// hasTokens returns true if s contains any tokens Parse would extract.
+func hasTokens(s string, now time.Time) bool {
+	return Parse(s, now).Title != strings.Join(strings.Fields(s), " ")
+}
+
+// parseDate parses a lower case single word date as midnight in now's location.
+// Weekday abbreviations are only accepted if abbreviated is true.
+func parseDate(s string, now time.Time, abbreviated bool) (time.Time, bool) {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	switch s {
+	case "today":
+		return today, true
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), true
+	}
+	if wd, ok := parseWeekday(s, abbreviated); ok {
+		// The next such weekday, a week from today if it's today.
+		days := (int(wd)-int(today.Weekday())+6)%7 + 1
+		return today.AddDate(0, 0, days), true
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, now.Location()); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+func parseWeekday(s string, abbreviated bool) (time.Weekday, bool) {
+	for wd := time.Sunday; wd <= time.Saturday; wd++ {
+		name := strings.ToLower(wd.String())
+		if s == name || (abbreviated && s == name[:3]) {
+			return wd, true
+		}
+	}
+	return 0, false
+}
+
+// parseRelative parses "in <n> <unit>" without the "in".
+func parseRelative(n, unit string, now time.Time) (time.Time, bool) {
+	count, err := strconv.Atoi(n)
+	if err != nil || count < 1 {
+		return time.Time{}, false
+	}
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	switch strings.TrimSuffix(unit, "s") {
+	case "day":
+		return today.AddDate(0, 0, count), true
+	case "week":
+		return today.AddDate(0, 0, 7*count), true
+	case "month":
+		return today.AddDate(0, count, 0), true
+	}
+	return time.Time{}, false
+}
+
+// parseTime parses a lower case time of day.
+func parseTime(s string) (hour, minute int, ok bool) {
+	if s == "noon" {
+		return 12, 0, true
+	}
+	if m := regexpTime12.FindStringSubmatch(s); m != nil {
+		hour, _ = strconv.Atoi(m[1])
+		if m[2] != "" {
+			minute, _ = strconv.Atoi(m[2])
+		}
+		if hour < 1 || hour > 12 || minute > 59 {
+			return 0, 0, false
+		}
+		hour %= 12
+		if m[3] == "pm" {
+			hour += 12
+		}
+		return hour, minute, true
+	}
+	if m := regexpTime24.FindStringSubmatch(s); m != nil {
+		hour, _ = strconv.Atoi(m[1])
+		minute, _ = strconv.Atoi(m[2])
+		if hour > 23 || minute > 59 {
+			return 0, 0, false
+		}
+		return hour, minute, true
+	}
+	return 0, 0, false
+}
+
+func parsePriority(s string) (repository.Priority, bool) {
+	if p, ok := priorities[strings.ToLower(s)]; ok {
+		return p, true
+	}
+	if len(s) == 1 {
+		p := repository.Priority(unicode.ToUpper(rune(s[0])))
+		if p != repository.PriorityNone && p.Valid() {
+			return p, true
+		}
+	}
+	return repository.PriorityNone, false
+}
+
+// isTag returns true if s starts with a letter, which keeps
+// things like "#1" and "+49" in the title.
+func isTag(s string) bool {
+	for _, r := range s {
+		return unicode.IsLetter(r)
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+package quickadd_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/quickadd"
+)
+
+// now is Monday, 2026-10-19 10:30 in a fixed zone ahead of UTC.
+var now = time.Date(2026, 10, 19, 10, 30, 0, 0, time.FixedZone("CEST", 2*60*60))
+
+func date(month time.Month, day, hour, minute int) time.Time {
+	return time.Date(2026, month, day, hour, minute, 0, 0, now.Location())
+}
+
+func TestParse(t *testing.T) {
+	for _, tt := range []struct {
+		input string
+		want  quickadd.Result
+	}{
+		{"Feed the cat", quickadd.Result{Title: "Feed the cat"}},
+		{"  Feed   the cat  ", quickadd.Result{Title: "Feed the cat"}},
+
+		// Dates.
+		{"Pay rent today", quickadd.Result{Title: "Pay rent", Due: date(10, 19, 0, 0)}},
+		{"Pay rent tomorrow", quickadd.Result{Title: "Pay rent", Due: date(10, 20, 0, 0)}},
+		{"Call mom Friday", quickadd.Result{Title: "Call mom", Due: date(10, 23, 0, 0)}},
+		{"Call mom on friday", quickadd.Result{Title: "Call mom", Due: date(10, 23, 0, 0)}},
+		// The same weekday is a week from today.
+		{"Standup monday", quickadd.Result{Title: "Standup", Due: date(10, 26, 0, 0)}},
+		{"Standup next mon", quickadd.Result{Title: "Standup", Due: date(10, 26, 0, 0)}},
+		{"Standup on tue", quickadd.Result{Title: "Standup", Due: date(10, 20, 0, 0)}},
+		{"Taxes by sunday", quickadd.Result{Title: "Taxes", Due: date(10, 25, 0, 0)}},
+		{"Ship on 2026-12-01", quickadd.Result{Title: "Ship", Due: date(12, 1, 0, 0)}},
+		{"Ship 2026-12-01", quickadd.Result{Title: "Ship", Due: date(12, 1, 0, 0)}},
+		{"Renew in 3 days", quickadd.Result{Title: "Renew", Due: date(10, 22, 0, 0)}},
+		{"Renew in 2 weeks", quickadd.Result{Title: "Renew", Due: date(11, 2, 0, 0)}},
+		{"Renew in 1 month", quickadd.Result{Title: "Renew", Due: date(11, 19, 0, 0)}},
+		{"Check in later", quickadd.Result{Title: "Check in later"}},
+		{"Only the first date today tomorrow", quickadd.Result{
+			Title: "Only the first date tomorrow", Due: date(10, 19, 0, 0),
+		}},
+
+		// Weekday abbreviations are common words.
+		{"Buy sun cream", quickadd.Result{Title: "Buy sun cream"}},
+		{"I sat down today", quickadd.Result{Title: "I sat down", Due: date(10, 19, 0, 0)}},
+		{"Mon cheri chocolate", quickadd.Result{Title: "Mon cheri chocolate"}},
+		{"Wed invitation", quickadd.Result{Title: "Wed invitation"}},
+		{"Go on", quickadd.Result{Title: "Go on"}},
+		{"Carry on sun cream", quickadd.Result{Title: "Carry cream", Due: date(10, 25, 0, 0)}},
+
+		// Times.
+		{"Dentist at 9am", quickadd.Result{Title: "Dentist", Due: date(10, 20, 9, 0)}},
+		{"Dentist 4:15pm", quickadd.Result{Title: "Dentist", Due: date(10, 19, 16, 15)}},
+		{"Dentist 16:15", quickadd.Result{Title: "Dentist", Due: date(10, 19, 16, 15)}},
+		{"Lunch noon", quickadd.Result{Title: "Lunch", Due: date(10, 19, 12, 0)}},
+		{"Dentist tomorrow 9am", quickadd.Result{Title: "Dentist", Due: date(10, 20, 9, 0)}},
+		{"Dentist 9am on friday", quickadd.Result{Title: "Dentist", Due: date(10, 23, 9, 0)}},
+		{"Score 25:00", quickadd.Result{Title: "Score 25:00"}},
+		{"Meet at home", quickadd.Result{Title: "Meet at home"}},
+
+		// Priorities and tags.
+		{"Fix bug !high", quickadd.Result{Title: "Fix bug", Priority: 'A'}},
+		{"Fix bug !med", quickadd.Result{Title: "Fix bug", Priority: 'B'}},
+		{"Fix bug !d", quickadd.Result{Title: "Fix bug", Priority: 'D'}},
+		{"Wow!", quickadd.Result{Title: "Wow!"}},
+		{"Fix !bug", quickadd.Result{Title: "Fix !bug"}},
+		{"Water plants #home +garden #home", quickadd.Result{
+			Title: "Water plants", Contexts: []string{"home"}, Projects: []string{"garden"},
+		}},
+		{"Call +49 123 about #1", quickadd.Result{Title: "Call +49 123 about #1"}},
+		{"Milk list:groceries", quickadd.Result{Title: "Milk", List: "groceries"}},
+
+		// Escaping.
+		{`Watch \tomorrow \#1`, quickadd.Result{Title: "Watch tomorrow #1"}},
+		{`Watch "Today Show" tomorrow`, quickadd.Result{
+			Title: "Watch Today Show", Due: date(10, 20, 0, 0),
+		}},
+		{`Read "on friday" #books`, quickadd.Result{
+			Title: "Read on friday", Contexts: []string{"books"},
+		}},
+		{`Say "hi" to mom`, quickadd.Result{Title: `Say "hi" to mom`}},
+		{`Read "The Road" tomorrow`, quickadd.Result{
+			Title: `Read "The Road"`, Due: date(10, 20, 0, 0),
+		}},
+		{`Unterminated "quote today`, quickadd.Result{
+			Title: `Unterminated "quote`, Due: date(10, 19, 0, 0),
+		}},
+	} {
+		t.Run(tt.input, func(t *testing.T) {
+			got := quickadd.Parse(tt.input, now)
+			if got.Title != tt.want.Title {
+				t.Errorf("title %q, want %q", got.Title, tt.want.Title)
+			}
+			if !got.Due.Equal(tt.want.Due) {
+				t.Errorf("due %v, want %v", got.Due, tt.want.Due)
+			}
+			if got.Priority != tt.want.Priority {
+				t.Errorf("priority %q, want %q", got.Priority, tt.want.Priority)
+			}
+			if !slices.Equal(got.Contexts, tt.want.Contexts) {
+				t.Errorf("contexts %q, want %q", got.Contexts, tt.want.Contexts)
+			}
+			if !slices.Equal(got.Projects, tt.want.Projects) {
+				t.Errorf("projects %q, want %q", got.Projects, tt.want.Projects)
+			}
+			if got.List != tt.want.List {
+				t.Errorf("list %q, want %q", got.List, tt.want.List)
+			}
+		})
+	}
+}
+
+func TestResultTodo(t *testing.T) {
+	r := quickadd.Parse("Dentist tomorrow 9am !high #health list:personal", now)
+	td := r.Todo()
+	if td.Title != "Dentist" || td.List != "personal" || td.Priority != 'A' ||
+		!td.Due.Equal(date(10, 20, 9, 0)) || !slices.Equal(td.Contexts, []string{"health"}) {
+		t.Errorf("todo: %#v", td)
+	}
+}
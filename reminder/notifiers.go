@@ -0,0 +1,85 @@
+package reminder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// SMTPNotifier sends reminders by email.
+// Addr is the host:port of the SMTP server. Auth may be nil
+// for local servers that don't require authentication.
+type SMTPNotifier struct {
+	Addr string
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+func (n *SMTPNotifier) Notify(ctx context.Context, r Notification) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", n.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(n.To, ", "))
+	fmt.Fprintf(&msg, "Subject: Reminder: %s\r\n", headerText(r.Title))
+	fmt.Fprintf(&msg, "Date: %s\r\n", r.At.Format(time.RFC1123Z))
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "%s\r\n", r.Title)
+	fmt.Fprintf(&msg, "List: %s\r\n", r.List)
+	if !r.Due.IsZero() {
+		fmt.Fprintf(&msg, "Due: %s\r\n", r.Due.Format(time.RFC1123))
+	}
+
+	// net/smtp doesn't support contexts, run it in the background
+	// to at least not block the scheduler after cancelation.
+	done := make(chan error, 1)
+	go func() { done <- smtp.SendMail(n.Addr, n.Auth, n.From, n.To, msg.Bytes()) }()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-done:
+		return err
+	}
+}
+
+// headerText prevents header injection through line breaks in s.
+func headerText(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// WebhookNotifier sends reminders as JSON in a POST request to URL.
+type WebhookNotifier struct {
+	URL    string
+	Client *http.Client // http.DefaultClient if nil.
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, r Notification) error {
+	body, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c := n.Client
+	if c == nil {
+		c = http.DefaultClient
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package reminder_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/reminder"
+)
+
+// mail is a message received by smtpSink.
+type mail struct {
+	From string
+	To   []string
+	Data string
+}
+
+// smtpSink starts a minimal local SMTP server
+// and returns its address and the received mails.
+func smtpSink(t *testing.T) (addr string, received <-chan mail) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+	c := make(chan mail, 4)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveSMTP(conn, c)
+		}
+	}()
+	return ln.Addr().String(), c
+}
+
+func serveSMTP(conn net.Conn, received chan<- mail) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	reply := func(s string) { _, _ = conn.Write([]byte(s + "\r\n")) }
+	reply("220 localhost ESMTP sink")
+	var m mail
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimRight(line, "\r\n")
+		switch verb := strings.ToUpper(strings.SplitN(cmd, " ", 2)[0]); verb {
+		case "EHLO", "HELO":
+			reply("250 localhost")
+		case "MAIL":
+			m.From = strings.Trim(strings.TrimPrefix(cmd[len("MAIL FROM:"):], " "), "<>")
+			reply("250 OK")
+		case "RCPT":
+			m.To = append(m.To, strings.Trim(cmd[len("RCPT TO:"):], " <>"))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(strings.TrimPrefix(l, "."))
+			}
+			m.Data = data.String()
+			received <- m
+			m = mail{}
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, received := smtpSink(t)
+	n := &reminder.SMTPNotifier{
+		Addr: addr,
+		From: "todo@example.com",
+		To:   []string{"a@example.com", "b@example.com"},
+	}
+	err := n.Notify(context.Background(), reminder.Notification{
+		TodoID: "1",
+		List:   "home",
+		Title:  "Feed the cat\r\nBcc: evil@example.com",
+		Due:    time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC),
+		At:     time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var m mail
+	select {
+	case m = <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail received")
+	}
+	if m.From != "todo@example.com" {
+		t.Errorf("from: %q", m.From)
+	}
+	if len(m.To) != 2 || m.To[0] != "a@example.com" || m.To[1] != "b@example.com" {
+		t.Errorf("to: %q", m.To)
+	}
+	header, body, ok := strings.Cut(m.Data, "\r\n\r\n")
+	if !ok {
+		t.Fatalf("no header: %q", m.Data)
+	}
+	for _, want := range []string{
+		"To: a@example.com, b@example.com\r\n",
+		"Subject: Reminder: Feed the cat  Bcc: evil@example.com\r\n",
+		"Date: Mon, 19 Oct 2026 09:00:00 +0000\r\n",
+	} {
+		if !strings.Contains(header+"\r\n", want) {
+			t.Errorf("header lacks %q:\n%s", want, header)
+		}
+	}
+	if strings.Contains(header, "\nBcc:") {
+		t.Errorf("header injected:\n%s", header)
+	}
+	for _, want := range []string{"List: home\r\n", "Due: Tue, 20 Oct 2026 00:00:00 UTC\r\n"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body lacks %q:\n%s", want, body)
+		}
+	}
+}
+
+func TestSMTPNotifierCanceled(t *testing.T) {
+	// A server that accepts connections but never greets.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n := &reminder.SMTPNotifier{Addr: ln.Addr().String(), From: "a@b.c", To: []string{"d@e.f"}}
+	if err := n.Notify(ctx, reminder.Notification{}); err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	want := reminder.Notification{
+		TodoID: "7",
+		List:   "work",
+		Title:  "Write report",
+		At:     time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC),
+	}
+	status := http.StatusNoContent
+	var got reminder.Notification
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request: %s %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Error(err)
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	n := &reminder.WebhookNotifier{URL: srv.URL}
+	if err := n.Notify(context.Background(), want); err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("received %#v, want %#v", got, want)
+	}
+
+	status = http.StatusInternalServerError
+	if err := n.Notify(context.Background(), want); err == nil {
+		t.Error("expected error for status 500")
+	}
+}
@@ -0,0 +1,134 @@
+// Package reminder dispatches notifications for todo reminders when they're due.
+package reminder
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// Clock provides the current time and timers.
+// Tests can use a controllable implementation instead of RealClock.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealClock is the system clock.
+type RealClock struct{}
+
+func (RealClock) Now() time.Time                         { return time.Now() }
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+// Notification is sent when a reminder is due.
+type Notification struct {
+	TodoID string    `json:"todoId"`
+	List   string    `json:"list"`
+	Title  string    `json:"title"`
+	Due    time.Time `json:"due,omitzero"`
+	At     time.Time `json:"at"`
+}
+
+// Notifier is a notification delivery channel.
+type Notifier interface {
+	Notify(ctx context.Context, n Notification) error
+}
+
+// MaxWait is the maximum time the scheduler sleeps without checking
+// for due reminders, which limits the effect of clock jumps.
+const MaxWait = time.Hour
+
+// Scheduler sends notifications to all notifiers when reminders are due.
+// Reminders are cleared once they were dispatched. Since reminders are
+// stored with the todos, reminders that became due while the server
+// wasn't running are dispatched right after starting.
+type Scheduler struct {
+	repo      *repository.Repository
+	clock     Clock
+	notifiers []Notifier
+	wake      chan struct{}
+}
+
+// NewScheduler creates a new scheduler. Call Run to start dispatching.
+func NewScheduler(
+	repo *repository.Repository, clock Clock, notifiers ...Notifier,
+) *Scheduler {
+	return &Scheduler{
+		repo:      repo,
+		clock:     clock,
+		notifiers: notifiers,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Wake makes the scheduler check for due reminders immediately.
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default: // Already woken.
+	}
+}
+
+// Run dispatches due reminders until ctx is canceled.
+func (s *Scheduler) Run(ctx context.Context) {
+	// Any change to a todo may change its reminder.
+	unsubscribe := s.repo.Subscribe(func(repository.Event) { s.Wake() })
+	defer unsubscribe()
+
+	for {
+		now := s.clock.Now()
+		due, next := s.repo.PendingReminders(now)
+		for _, t := range due {
+			s.dispatch(ctx, t, now)
+		}
+
+		wait := MaxWait
+		if !next.IsZero() {
+			wait = min(wait, next.Sub(now))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-s.clock.After(wait):
+		}
+	}
+}
+
+// dispatch notifies all notifiers and clears the reminder of t.
+// Failed deliveries are logged and not retried.
+func (s *Scheduler) dispatch(ctx context.Context, t repository.Todo, now time.Time) {
+	n := Notification{
+		TodoID: t.ID,
+		List:   t.List,
+		Title:  t.Title,
+		Due:    t.Due,
+		At:     t.Remind,
+	}
+	for _, notifier := range s.notifiers {
+		if err := notifier.Notify(ctx, n); err != nil {
+			slog.Error("sending reminder",
+				slog.String("todo", t.ID),
+				slog.String("notifier", notifierName(notifier)),
+				slog.Any("err", err))
+		}
+	}
+	if err := s.repo.ClearReminder(t.ID, t.Remind); err != nil {
+		slog.Error("clearing reminder", slog.String("todo", t.ID), slog.Any("err", err))
+	}
+	slog.Info("reminder dispatched",
+		slog.String("todo", t.ID),
+		slog.Duration("late", now.Sub(t.Remind)))
+}
+
+func notifierName(n Notifier) string {
+	switch n.(type) {
+	case *SMTPNotifier:
+		return "smtp"
+	case *WebhookNotifier:
+		return "webhook"
+	}
+	return "other"
+}
@@ -0,0 +1,215 @@
+package reminder_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/reminder"
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// fakeClock is a reminder.Clock that only moves when advanced.
+// Every call to After is reported on sleeps.
+type fakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []timer
+	sleeps chan time.Duration
+}
+
+type timer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, sleeps: make(chan time.Duration, 16)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	t := timer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	c.timers = append(c.timers, t)
+	c.sleeps <- d
+	return t.c
+}
+
+// Advance moves the clock forward by d and fires all expired timers.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = pending
+}
+
+// expectSleep waits for the scheduler to sleep for d.
+func (c *fakeClock) expectSleep(t *testing.T, d time.Duration) {
+	t.Helper()
+	select {
+	case got := <-c.sleeps:
+		if got != d {
+			t.Fatalf("scheduler sleeps %v, want %v", got, d)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("scheduler didn't sleep")
+	}
+}
+
+// recorder is a reminder.Notifier recording all notifications.
+type recorder chan reminder.Notification
+
+func (r recorder) Notify(_ context.Context, n reminder.Notification) error {
+	r <- n
+	return nil
+}
+
+func (r recorder) expect(t *testing.T, todoID string) reminder.Notification {
+	t.Helper()
+	select {
+	case n := <-r:
+		if n.TodoID != todoID {
+			t.Fatalf("notified of %q, want %q", n.TodoID, todoID)
+		}
+		return n
+	case <-time.After(5 * time.Second):
+		t.Fatalf("%q wasn't notified", todoID)
+	}
+	return reminder.Notification{}
+}
+
+func addWithReminder(
+	t *testing.T, repo *repository.Repository, title string, done bool, at time.Time,
+) string {
+	t.Helper()
+	id, err := repo.Add(title, done, at.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.SetReminder(id, at); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestScheduler(t *testing.T) {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	repo, err := repository.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	// Became due while the server wasn't running.
+	missed := addWithReminder(t, repo, "Missed", false, start.Add(-time.Hour))
+	later := addWithReminder(t, repo, "Later", false, start.Add(time.Hour))
+	// Done todos are never reminded of.
+	_ = addWithReminder(t, repo, "Done", true, start.Add(-time.Minute))
+
+	cleared := make(chan repository.Event, 4)
+	defer repo.Subscribe(func(e repository.Event) {
+		if e.Type == repository.EventUpdated && e.Todo.Remind.IsZero() {
+			cleared <- e
+		}
+	})()
+
+	clock := newFakeClock(start)
+	notified := make(recorder, 4)
+	s := reminder.NewScheduler(repo, clock, notified)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() { s.Run(ctx); close(stopped) }()
+	defer func() { cancel(); <-stopped }()
+
+	n := notified.expect(t, missed)
+	if n.Title != "Missed" || !n.At.Equal(start.Add(-time.Hour)) {
+		t.Errorf("notification: %#v", n)
+	}
+	// Clearing the reminder wakes the scheduler once more.
+	clock.expectSleep(t, time.Hour)
+	clock.expectSleep(t, time.Hour)
+
+	select {
+	case e := <-cleared:
+		if e.Todo.ID != missed || !e.Previous.Remind.Equal(start.Add(-time.Hour)) {
+			t.Errorf("clear event: %#v", e)
+		}
+	default:
+		t.Error("reminder wasn't cleared")
+	}
+	select {
+	case n := <-notified:
+		t.Fatalf("unexpected notification: %#v", n)
+	default:
+	}
+
+	clock.Advance(time.Hour)
+	notified.expect(t, later)
+	clock.expectSleep(t, reminder.MaxWait)
+	clock.expectSleep(t, reminder.MaxWait)
+
+	for _, id := range []string{missed, later} {
+		td, err := repo.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !td.Remind.IsZero() {
+			t.Errorf("reminder of %q not cleared: %v", id, td.Remind)
+		}
+	}
+}
+
+func TestSchedulerWakesOnNewReminder(t *testing.T) {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	repo, err := repository.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+
+	clock := newFakeClock(start)
+	notified := make(recorder, 4)
+	s := reminder.NewScheduler(repo, clock, notified)
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() { s.Run(ctx); close(stopped) }()
+	defer func() { cancel(); <-stopped }()
+
+	clock.expectSleep(t, reminder.MaxWait)
+	id := addWithReminder(t, repo, "Soon", false, start.Add(10*time.Minute))
+	// Woken by the creation and the reminder being set,
+	// which may be coalesced into a single wake-up.
+	for {
+		select {
+		case d := <-clock.sleeps:
+			if d == reminder.MaxWait {
+				continue
+			}
+			if d != 10*time.Minute {
+				t.Fatalf("scheduler sleeps %v, want 10m", d)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("scheduler didn't sleep")
+		}
+		break
+	}
+
+	clock.Advance(10 * time.Minute)
+	notified.expect(t, id)
+}
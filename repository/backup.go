@@ -0,0 +1,306 @@
+package repository
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// BackupFormatVersion is the current version of the backup archive format.
+const BackupFormatVersion = 1
+
+// fileManifest is the name of the manifest within backup archives.
+// The manifest is always the last entry of the archive.
+const fileManifest = "manifest.json"
+
+// Manifest describes the contents of a backup archive.
+type Manifest struct {
+	FormatVersion int            `json:"formatVersion"`
+	SchemaVersion int            `json:"schemaVersion"`
+	Created       time.Time      `json:"created"`
+	Files         []ManifestFile `json:"files"`
+}
+
+// ManifestFile is a file contained in a backup archive.
+type ManifestFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+var ErrNotPersistent = errors.New("repository isn't persisted on disk")
+
+// Backup writes a gzip compressed tar archive of the store file and the search index
+// to w. Writes are blocked during the backup so the archive is always consistent,
+// reads are not.
+// Returns ErrNotPersistent for in-memory repositories.
+func (s *Repository) Backup(w io.Writer, now time.Time) error {
+	if s.dir == "" {
+		return ErrNotPersistent
+	}
+	return s.View(func(*Tx) error { return s.backup(w, now) })
+}
+
+// backup must be called with s.lock held.
+func (s *Repository) backup(w io.Writer, now time.Time) error {
+	copyable, ok := s.index.(bleve.IndexCopyable)
+	if !ok {
+		return errors.New("search index doesn't support online copy")
+	}
+
+	gz := gzip.NewWriter(w)
+	a := &archiveWriter{tw: tar.NewWriter(gz), now: now}
+
+	store, err := s.marshalStore()
+	if err != nil {
+		return err
+	}
+	if err := a.writeFile(FileStore, store); err != nil {
+		return err
+	}
+	// Bleve can only copy the index to the file system.
+	tmp, err := os.MkdirTemp("", "todoapp-backup-*")
+	if err != nil {
+		return fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	if err := copyable.CopyTo(bleve.FileSystemDirectory(tmp)); err != nil {
+		return fmt.Errorf("copying search index: %w", err)
+	}
+	err = filepath.WalkDir(tmp, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(tmp, p)
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		return a.writeFile(path.Join(DirIndex, filepath.ToSlash(rel)), data)
+	})
+	if err != nil {
+		return fmt.Errorf("archiving search index: %w", err)
+	}
+
+	manifest, err := json.MarshalIndent(Manifest{
+		FormatVersion: BackupFormatVersion,
+		SchemaVersion: SchemaVersion,
+		Created:       now,
+		Files:         a.files,
+	}, "", "\t")
+	if err != nil {
+		return fmt.Errorf("encoding manifest: %w", err)
+	}
+	if err := a.writeFile(fileManifest, manifest); err != nil {
+		return err
+	}
+	if err := a.tw.Close(); err != nil {
+		return fmt.Errorf("closing tar writer: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("closing gzip writer: %w", err)
+	}
+	return nil
+}
+
+type archiveWriter struct {
+	tw    *tar.Writer
+	now   time.Time
+	files []ManifestFile
+}
+
+func (a *archiveWriter) writeFile(name string, data []byte) error {
+	err := a.tw.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Size:     int64(len(data)),
+		Mode:     0o644,
+		ModTime:  a.now,
+	})
+	if err != nil {
+		return fmt.Errorf("writing tar header for %q: %w", name, err)
+	}
+	if _, err := a.tw.Write(data); err != nil {
+		return fmt.Errorf("writing %q: %w", name, err)
+	}
+	if name != fileManifest {
+		sum := sha256.Sum256(data)
+		a.files = append(a.files, ManifestFile{
+			Name:   name,
+			Size:   int64(len(data)),
+			SHA256: hex.EncodeToString(sum[:]),
+		})
+	}
+	return nil
+}
+
+// Restore extracts the backup archive read from r into dir, which must either
+// not exist or be empty. The checksums and the schema version are verified
+// before anything is written to dir. Stores of earlier schema versions
+// are restored as is and migrated by Open.
+func Restore(r io.Reader, dir string) (Manifest, error) {
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return Manifest{}, fmt.Errorf("data directory %q isn't empty", dir)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, fmt.Errorf("reading data directory: %w", err)
+	}
+
+	parent := filepath.Dir(filepath.Clean(dir))
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return Manifest{}, fmt.Errorf("creating parent directory: %w", err)
+	}
+	tmp, err := os.MkdirTemp(parent, ".restore-*")
+	if err != nil {
+		return Manifest{}, fmt.Errorf("creating temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tmp) // No-op after successful rename.
+
+	m, err := extractArchive(r, tmp)
+	if err != nil {
+		return Manifest{}, err
+	}
+
+	// dir is guaranteed to be empty at this point.
+	if err := os.Remove(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return Manifest{}, fmt.Errorf("removing empty data directory: %w", err)
+	}
+	if err := os.Rename(tmp, dir); err != nil {
+		return Manifest{}, fmt.Errorf("moving restored data directory: %w", err)
+	}
+	return m, nil
+}
+
+// extractArchive extracts all files into dir and verifies them against the manifest.
+func extractArchive(r io.Reader, dir string) (m Manifest, err error) {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("reading gzip: %w", err)
+	}
+	tr := tar.NewReader(gz)
+
+	extracted := map[string]ManifestFile{}
+	var manifest []byte
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return Manifest{}, fmt.Errorf("reading tar: %w", err)
+		}
+		if manifest != nil {
+			return Manifest{}, fmt.Errorf("unexpected entry after manifest: %q", h.Name)
+		}
+		if h.Typeflag != tar.TypeReg {
+			return Manifest{}, fmt.Errorf("unsupported entry type: %q", h.Name)
+		}
+		if h.Name == fileManifest {
+			if manifest, err = io.ReadAll(tr); err != nil {
+				return Manifest{}, fmt.Errorf("reading manifest: %w", err)
+			}
+			continue
+		}
+		f, err := extractFile(tr, dir, h.Name)
+		if err != nil {
+			return Manifest{}, err
+		}
+		extracted[f.Name] = f
+	}
+	if manifest == nil {
+		return Manifest{}, errors.New("missing manifest")
+	}
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return Manifest{}, fmt.Errorf("decoding manifest: %w", err)
+	}
+
+	if m.FormatVersion != BackupFormatVersion {
+		return Manifest{}, fmt.Errorf(
+			"unsupported backup format version %d, expected %d",
+			m.FormatVersion, BackupFormatVersion,
+		)
+	}
+	if m.SchemaVersion < 1 || m.SchemaVersion > SchemaVersion {
+		return Manifest{}, fmt.Errorf(
+			"unsupported store schema version %d, expected 1 to %d",
+			m.SchemaVersion, SchemaVersion,
+		)
+	}
+	if len(m.Files) != len(extracted) {
+		return Manifest{}, fmt.Errorf(
+			"manifest lists %d files, archive contains %d", len(m.Files), len(extracted),
+		)
+	}
+	for _, f := range m.Files {
+		if extracted[f.Name] != f {
+			return Manifest{}, fmt.Errorf("checksum mismatch: %q", f.Name)
+		}
+	}
+	if !slices.ContainsFunc(m.Files, func(f ManifestFile) bool {
+		return f.Name == FileStore
+	}) {
+		return Manifest{}, fmt.Errorf("missing %q", FileStore)
+	}
+
+	store, err := os.ReadFile(filepath.Join(dir, FileStore))
+	if err != nil {
+		return Manifest{}, fmt.Errorf("reading restored store file: %w", err)
+	}
+	var f struct {
+		Version int `json:"version"`
+	}
+	if err := json.Unmarshal(store, &f); err != nil {
+		return Manifest{}, fmt.Errorf("decoding restored store file: %w", err)
+	}
+	if f.Version != m.SchemaVersion {
+		return Manifest{}, fmt.Errorf(
+			"store schema version %d doesn't match the manifest's version %d",
+			f.Version, m.SchemaVersion,
+		)
+	}
+	return m, nil
+}
+
+// extractFile writes the current tar entry to dir and returns its checksum.
+func extractFile(r io.Reader, dir, name string) (ManifestFile, error) {
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return ManifestFile{}, fmt.Errorf("illegal path in archive: %q", name)
+	}
+	p := filepath.Join(dir, filepath.FromSlash(clean))
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return ManifestFile{}, fmt.Errorf("creating directory for %q: %w", name, err)
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("creating %q: %w", name, err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, hash), r)
+	if err != nil {
+		return ManifestFile{}, fmt.Errorf("extracting %q: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return ManifestFile{}, fmt.Errorf("closing %q: %w", name, err)
+	}
+	return ManifestFile{
+		Name: clean, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
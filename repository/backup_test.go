@@ -0,0 +1,207 @@
+package repository_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// newBackup returns a backup of a repository with todos and
+// the todos in order.
+func newBackup(t *testing.T) ([]byte, []repository.Todo) {
+	t.Helper()
+	r, err := repository.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if _, err := r.Import([]repository.Todo{
+		{Title: "Buy milk"}, {Title: "Feed the cat", List: "home", Done: true},
+	}, time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := r.Backup(&b, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes(), snapshot(t, r)
+}
+
+// rewriteArchive returns archive with the contents of each file replaced by fn.
+func rewriteArchive(t *testing.T, archive []byte, fn func(name string, data []byte) []byte) []byte {
+	t.Helper()
+	gr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gr)
+	var b bytes.Buffer
+	gw := gzip.NewWriter(&b)
+	tw := tar.NewWriter(gw)
+	for {
+		h, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = fn(h.Name, data)
+		h.Size = int64(len(data))
+		if err := tw.WriteHeader(h); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+// setManifestSchemaVersion returns manifest with the given schema version.
+func setManifestSchemaVersion(t *testing.T, manifest []byte, version int) []byte {
+	t.Helper()
+	var m repository.Manifest
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		t.Fatal(err)
+	}
+	m.SchemaVersion = version
+	b, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBackupRestore(t *testing.T) {
+	archive, todos := newBackup(t)
+	dir := filepath.Join(t.TempDir(), "data")
+	m, err := repository.Restore(bytes.NewReader(archive), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.FormatVersion != repository.BackupFormatVersion ||
+		m.SchemaVersion != repository.SchemaVersion || len(m.Files) < 2 {
+		t.Fatalf("manifest %+v", m)
+	}
+
+	r, err := repository.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if restored := snapshot(t, r); !reflect.DeepEqual(restored, todos) {
+		t.Errorf("restored todos:\n got %#v\nwant %#v", restored, todos)
+	}
+	found, err := r.Find(context.Background(), "cat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].Title != "Feed the cat" {
+		t.Errorf("restored index found %v", found)
+	}
+
+	// Only empty data directories are restored into.
+	if _, err := repository.Restore(bytes.NewReader(archive), dir); err == nil {
+		t.Error("restored into a non-empty data directory")
+	}
+}
+
+func TestRestoreErrors(t *testing.T) {
+	archive, _ := newBackup(t)
+	for _, tt := range []struct {
+		name    string
+		archive []byte
+		err     string
+	}{
+		{
+			name:    "truncated",
+			archive: archive[:len(archive)/2],
+			err:     "unexpected EOF",
+		},
+		{
+			name:    "not gzip",
+			archive: []byte("todos"),
+			err:     "reading gzip",
+		},
+		{
+			name: "checksum mismatch",
+			archive: rewriteArchive(t, archive, func(name string, data []byte) []byte {
+				if name == repository.FileStore {
+					return bytes.Replace(data, []byte("Buy milk"), []byte("Buy wine"), 1)
+				}
+				return data
+			}),
+			err: `checksum mismatch: "todos.json"`,
+		},
+		{
+			name: "newer schema",
+			archive: rewriteArchive(t, archive, func(name string, data []byte) []byte {
+				if name == "manifest.json" {
+					return setManifestSchemaVersion(t, data, repository.SchemaVersion+1)
+				}
+				return data
+			}),
+			err: "unsupported store schema version",
+		},
+		{
+			name: "schema differs from store",
+			archive: rewriteArchive(t, archive, func(name string, data []byte) []byte {
+				if name == "manifest.json" {
+					return setManifestSchemaVersion(t, data, repository.SchemaVersion-1)
+				}
+				return data
+			}),
+			err: "doesn't match the manifest's version",
+		},
+		{
+			name: "empty manifest",
+			archive: rewriteArchive(t, archive, func(name string, data []byte) []byte {
+				if name == "manifest.json" {
+					return []byte("{}")
+				}
+				return data
+			}),
+			err: "unsupported backup format version 0",
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "data")
+			_, err := repository.Restore(bytes.NewReader(tt.archive), dir)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("expected error %q, got %v", tt.err, err)
+			}
+			if _, err := os.Stat(dir); !os.IsNotExist(err) {
+				t.Errorf("data directory was created: %v", err)
+			}
+		})
+	}
+}
+
+func TestBackupNotPersistent(t *testing.T) {
+	r := newRepository(t)
+	if err := r.Backup(io.Discard, time.Now()); !errors.Is(err, repository.ErrNotPersistent) {
+		t.Fatalf("expected ErrNotPersistent, got %v", err)
+	}
+}
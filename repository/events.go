@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is the type of change an Event describes.
+type EventType string
+
+const (
+	EventCreated EventType = "todo.created"
+	EventUpdated EventType = "todo.updated"
+	EventToggled EventType = "todo.toggled"
+	EventDeleted EventType = "todo.deleted"
+
+	// EventRenamed is emitted in addition to EventUpdated
+	// whenever the title changes.
+	EventRenamed EventType = "todo.renamed"
+)
+
+// Event is emitted after a todo was changed.
+type Event struct {
+	Type EventType
+	// Todo is the new state of the todo, or the last state if it was deleted.
+	Todo Todo
+	// Previous is the state before the change
+	// for EventUpdated, EventToggled and EventRenamed.
+	Previous Todo
+	Time     time.Time
+}
+
+// listeners is a set of event listeners.
+type listeners struct {
+	lock    sync.Mutex
+	counter uint64
+	fns     map[uint64]func(Event)
+}
+
+// Subscribe registers fn to be called for every event until unsubscribe is called.
+// fn is called synchronously after the change was committed without the repository
+// lock held, so it may read from the repository, but it must not block for long.
+func (s *Repository) Subscribe(fn func(Event)) (unsubscribe func()) {
+	l := &s.listeners
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.fns == nil {
+		l.fns = map[uint64]func(Event){}
+	}
+	l.counter++
+	id := l.counter
+	l.fns[id] = fn
+	return func() {
+		l.lock.Lock()
+		defer l.lock.Unlock()
+		delete(l.fns, id)
+	}
+}
+
+// emit calls all listeners for each event. Methods defer emit before locking
+// s.lock to emit the events they collected after unlocking.
+func (s *Repository) emit(events *[]Event) {
+	if len(*events) < 1 {
+		return
+	}
+	l := &s.listeners
+	l.lock.Lock()
+	fns := make([]func(Event), 0, len(l.fns))
+	for _, fn := range l.fns {
+		fns = append(fns, fn)
+	}
+	l.lock.Unlock()
+
+	for _, e := range *events {
+		for _, fn := range fns {
+			fn(e)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"strings"
+	"time"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search"
+)
+
+// Facet is the number of search results by value of a todo property.
+type Facet struct {
+	Name   string
+	Values []FacetValue
+}
+
+// FacetValue is the number of search results with a value of a facet.
+type FacetValue struct {
+	Label string
+	// Query is the qualifier narrowing a search to the value (see ParseQuery).
+	Query string
+	Count int
+}
+
+// MaxFacetTags is the maximum number of tags of the tag facets.
+const MaxFacetTags = 10
+
+// createdBucket is a range of creation dates of the created facet.
+type createdBucket struct {
+	label string
+	// days is the number of days back from today the bucket starts at,
+	// negative for the bucket without a start.
+	days int
+}
+
+// createdBuckets are ordered from newest to oldest. Each bucket ends where
+// the previous one starts, so every todo is counted in exactly one bucket.
+var createdBuckets = []createdBucket{
+	{label: "Today", days: 0},
+	{label: "Last 7 days", days: 6},
+	{label: "Last 30 days", days: 29},
+	{label: "Older", days: -1},
+}
+
+// createdRange is the range of creation dates of a bucket,
+// the start is inclusive and the end exclusive. Zero times are unbounded.
+type createdRange struct {
+	label      string
+	start, end time.Time
+}
+
+// createdRanges returns the ranges of createdBuckets relative to now.
+func createdRanges(now time.Time) []createdRange {
+	today := startOfDay(now)
+	r := make([]createdRange, len(createdBuckets))
+	var end time.Time
+	for i, b := range createdBuckets {
+		r[i] = createdRange{label: b.label, end: end}
+		if b.days >= 0 {
+			r[i].start = today.AddDate(0, 0, -b.days)
+		}
+		end = r[i].start
+	}
+	return r
+}
+
+// query returns the qualifiers narrowing a search to r.
+func (r createdRange) query() string {
+	var q []string
+	if !r.start.IsZero() {
+		q = append(q, "created:>="+r.start.Format(time.DateOnly))
+	}
+	if !r.end.IsZero() {
+		q = append(q, "created:<"+r.end.Format(time.DateOnly))
+	}
+	return strings.Join(q, " ")
+}
+
+func addFacetRequests(req *bleve.SearchRequest, now time.Time) {
+	req.AddFacet("status", bleve.NewFacetRequest("done", 2))
+	req.AddFacet("contexts", bleve.NewFacetRequest("contexts", MaxFacetTags))
+	req.AddFacet("projects", bleve.NewFacetRequest("projects", MaxFacetTags))
+
+	priority := bleve.NewFacetRequest("priority", 'Z'-'A'+1)
+	for p := Priority('A'); p <= 'Z'; p++ {
+		from, to := float64(p), float64(p+1)
+		priority.AddNumericRange(p.String(), &from, &to)
+	}
+	req.AddFacet("priority", priority)
+
+	created := bleve.NewFacetRequest("created", len(createdBuckets))
+	for _, r := range createdRanges(now) {
+		created.AddDateTimeRange(r.label, r.start, r.end)
+	}
+	req.AddFacet("created", created)
+}
+
+// facets returns the non-empty facets of the results
+// of a request prepared by addFacetRequests.
+func facets(res search.FacetResults, now time.Time) []Facet {
+	var r []Facet
+	add := func(name string, values []FacetValue) {
+		if len(values) > 0 {
+			r = append(r, Facet{Name: name, Values: values})
+		}
+	}
+
+	var status []FacetValue
+	for _, t := range res["status"].Terms.Terms() {
+		// Booleans are indexed as the terms "T" and "F".
+		switch t.Term {
+		case "F":
+			status = append(status, FacetValue{Label: "Open", Query: "done:false", Count: t.Count})
+		case "T":
+			status = append(status, FacetValue{Label: "Done", Query: "done:true", Count: t.Count})
+		}
+	}
+	add("Status", status)
+
+	var tags []FacetValue
+	for _, t := range res["contexts"].Terms.Terms() {
+		tags = append(tags, FacetValue{Label: "#" + t.Term, Query: "tag:" + t.Term, Count: t.Count})
+	}
+	for _, t := range res["projects"].Terms.Terms() {
+		tags = append(tags, FacetValue{Label: "+" + t.Term, Query: "tag:" + t.Term, Count: t.Count})
+	}
+	add("Tags", tags)
+
+	var priorities []FacetValue
+	counts := map[string]int{}
+	for _, f := range res["priority"].NumericRanges {
+		counts[f.Name] = f.Count
+	}
+	for p := Priority('A'); p <= 'Z'; p++ {
+		if n := counts[p.String()]; n > 0 {
+			priorities = append(priorities, FacetValue{
+				Label: p.String(), Query: "priority:" + p.String(), Count: n,
+			})
+		}
+	}
+	add("Priority", priorities)
+
+	var created []FacetValue
+	counts = map[string]int{}
+	for _, f := range res["created"].DateRanges {
+		counts[f.Name] = f.Count
+	}
+	for _, r := range createdRanges(now) {
+		if n := counts[r.label]; n > 0 {
+			created = append(created, FacetValue{Label: r.label, Query: r.query(), Count: n})
+		}
+	}
+	add("Created", created)
+
+	return r
+}
+
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
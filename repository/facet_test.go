@@ -0,0 +1,77 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+func TestFacets(t *testing.T) {
+	now := time.Now()
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	daysAgo := func(n int) time.Time { return today.AddDate(0, 0, -n) }
+
+	r := newRepository(t)
+	if _, err := r.Import([]repository.Todo{
+		// Today.
+		{Title: "Todo 1", Created: today, Done: true, Contexts: []string{"home"}},
+		{Title: "Todo 2", Created: now, Priority: 'A', Contexts: []string{"home"}},
+		// Last 7 days.
+		{Title: "Todo 3", Created: today.Add(-time.Nanosecond), Projects: []string{"garden"}},
+		{Title: "Todo 4", Created: daysAgo(6), Priority: 'A'},
+		// Last 30 days.
+		{Title: "Todo 5", Created: daysAgo(6).Add(-time.Nanosecond), Done: true},
+		{Title: "Todo 6", Created: daysAgo(29), Priority: 'C'},
+		// Older.
+		{Title: "Todo 7", Created: daysAgo(29).Add(-time.Nanosecond)},
+		{Title: "Todo 8", Created: daysAgo(400)},
+	}, now); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := r.FindPage(context.Background(), "todo", repository.SortNewest, "", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(n int) string { return daysAgo(n).Format(time.DateOnly) }
+	want := []repository.Facet{
+		{Name: "Status", Values: []repository.FacetValue{
+			{Label: "Open", Query: "done:false", Count: 6},
+			{Label: "Done", Query: "done:true", Count: 2},
+		}},
+		{Name: "Tags", Values: []repository.FacetValue{
+			{Label: "#home", Query: "tag:home", Count: 2},
+			{Label: "+garden", Query: "tag:garden", Count: 1},
+		}},
+		{Name: "Priority", Values: []repository.FacetValue{
+			{Label: "A", Query: "priority:A", Count: 2},
+			{Label: "C", Query: "priority:C", Count: 1},
+		}},
+		{Name: "Created", Values: []repository.FacetValue{
+			{Label: "Today", Query: "created:>=" + date(0), Count: 2},
+			{Label: "Last 7 days", Query: "created:>=" + date(6) + " created:<" + date(0), Count: 2},
+			{Label: "Last 30 days", Query: "created:>=" + date(29) + " created:<" + date(6), Count: 2},
+			{Label: "Older", Query: "created:<" + date(29), Count: 2},
+		}},
+	}
+	if !reflect.DeepEqual(p.Facets, want) {
+		t.Fatalf("facets:\n got %+v\nwant %+v", p.Facets, want)
+	}
+
+	// The query of every value narrows the search to its count.
+	for _, f := range p.Facets {
+		for _, v := range f.Values {
+			found, err := r.Find(context.Background(), "todo "+v.Query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found) != v.Count {
+				t.Errorf("%s %s: %d results, want %d", f.Name, v.Label, len(found), v.Count)
+			}
+		}
+	}
+}
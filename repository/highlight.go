@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/blevesearch/bleve/v2/search/highlight"
+	simpleFragmenter "github.com/blevesearch/bleve/v2/search/highlight/fragmenter/simple"
+	simpleHighlighter "github.com/blevesearch/bleve/v2/search/highlight/highlighter/simple"
+)
+
+// Segment is a part of a highlighted text.
+type Segment struct {
+	Text string
+	// Match is true if Text matched the search term.
+	Match bool
+}
+
+// highlighterSegments is the name of the bleve highlighter used for search results.
+// Instead of markup its formatter encloses matches in markers that
+// parseSegments turns into segments, leaving escaping to the renderer.
+const highlighterSegments = "todo-segments"
+
+const (
+	markerStart = '\uE000'
+	markerEnd   = '\uE001'
+)
+
+func init() {
+	registry.RegisterFragmentFormatter(highlighterSegments,
+		func(map[string]any, *registry.Cache) (highlight.FragmentFormatter, error) {
+			return segmentsFormatter{}, nil
+		})
+	registry.RegisterHighlighter(highlighterSegments,
+		func(_ map[string]any, cache *registry.Cache) (highlight.Highlighter, error) {
+			fragmenter, err := cache.FragmenterNamed(simpleFragmenter.Name)
+			if err != nil {
+				return nil, fmt.Errorf("building fragmenter: %w", err)
+			}
+			formatter, err := cache.FragmentFormatterNamed(highlighterSegments)
+			if err != nil {
+				return nil, fmt.Errorf("building fragment formatter: %w", err)
+			}
+			return simpleHighlighter.NewHighlighter(
+				fragmenter, formatter, simpleHighlighter.DefaultSeparator,
+			), nil
+		})
+}
+
+type segmentsFormatter struct{}
+
+func (segmentsFormatter) Format(
+	f *highlight.Fragment, locations highlight.TermLocations,
+) string {
+	var b strings.Builder
+	curr := f.Start
+	for _, l := range locations {
+		if l == nil || !l.ArrayPositions.Equals(f.ArrayPositions) || l.Start < curr {
+			continue
+		}
+		if l.End > f.End {
+			break
+		}
+		b.Write(stripMarkers(f.Orig[curr:l.Start]))
+		b.WriteRune(markerStart)
+		b.Write(stripMarkers(f.Orig[l.Start:l.End]))
+		b.WriteRune(markerEnd)
+		curr = l.End
+	}
+	b.Write(stripMarkers(f.Orig[curr:f.End]))
+	return b.String()
+}
+
+// stripMarkers removes markers from the original text
+// so they can't be confused with the ones added by the formatter.
+func stripMarkers(b []byte) []byte {
+	s := strings.NewReplacer(string(markerStart), "", string(markerEnd), "").
+		Replace(string(b))
+	return []byte(s)
+}
+
+// parseSegments parses a fragment formatted by segmentsFormatter.
+func parseSegments(fragment string) []Segment {
+	var segments []Segment
+	match := false
+	for fragment != "" {
+		marker := markerStart
+		if match {
+			marker = markerEnd
+		}
+		text, rest, found := strings.Cut(fragment, string(marker))
+		if text != "" {
+			segments = append(segments, Segment{Text: text, Match: match})
+		}
+		if !found {
+			break
+		}
+		fragment, match = rest, !match
+	}
+	return segments
+}
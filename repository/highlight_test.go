@@ -0,0 +1,51 @@
+package repository_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+func TestHighlights(t *testing.T) {
+	r := newRepository(t)
+	titles := map[string][]repository.Segment{
+		"Feed the <b>cat</b> now": {
+			{Text: "Feed the <b>"}, {Text: "cat", Match: true}, {Text: "</b> now"},
+		},
+		"Cat food for cats": {
+			{Text: "Cat", Match: true}, {Text: " food for "}, {Text: "cats", Match: true},
+		},
+		// Private use characters in titles are removed.
+		"Pet \ue001the\ue000 \ue000cat\ue001": {
+			{Text: "Pet the "}, {Text: "cat", Match: true},
+		},
+	}
+	ids := map[string]string{}
+	for title := range titles {
+		id, err := r.Add(title, false, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids[id] = title
+	}
+	if _, err := r.Add("Walk the dog", false, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := r.FindPage(context.Background(), "cat", repository.SortRelevance, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Todos) != len(titles) || len(p.Highlights) != len(titles) {
+		t.Fatalf("%d results with %d highlights, want %d",
+			len(p.Todos), len(p.Highlights), len(titles))
+	}
+	for id, segments := range p.Highlights {
+		if want := titles[ids[id]]; !reflect.DeepEqual(segments, want) {
+			t.Errorf("%q: %+v, want %+v", ids[id], segments, want)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"errors"
+	"time"
+)
+
+// MaxHistory is the number of undoable changes kept in memory.
+const MaxHistory = 20
+
+var ErrNothingToUndo = errors.New("nothing to undo")
+
+// historyEntry is an undoable change made by UpdateUndoable.
+type historyEntry struct {
+	action string
+	// changes are the events of the change, at most one per todo.
+	changes []Event
+}
+
+// UpdateUndoable is Update recording all changes fn makes as a single
+// history entry described by action, which Undo reverts at once.
+// The history is kept in memory only and limited to MaxHistory entries.
+func (s *Repository) UpdateUndoable(action string, fn func(tx *Tx) error) error {
+	return s.Update(func(tx *Tx) error {
+		from := len(tx.events)
+		if err := fn(tx); err != nil {
+			return err
+		}
+		e := historyEntry{action: action}
+		seen := map[string]bool{}
+		for _, ev := range tx.events[from:] {
+			if ev.Type == EventRenamed || seen[ev.Todo.ID] {
+				// Renames are accompanied by EventUpdated.
+				continue
+			}
+			seen[ev.Todo.ID] = true
+			e.changes = append(e.changes, ev)
+		}
+		if len(e.changes) < 1 {
+			return nil
+		}
+		s.history = append(s.history, e)
+		if len(s.history) > MaxHistory {
+			s.history = s.history[len(s.history)-MaxHistory:]
+		}
+		return nil
+	})
+}
+
+// UndoAction returns the action of the change Undo would revert,
+// empty if there's nothing to undo.
+func (s *Repository) UndoAction() (action string) {
+	_ = s.View(func(tx *Tx) error {
+		action = tx.UndoAction()
+		return nil
+	})
+	return action
+}
+
+// UndoAction is Repository.UndoAction within the transaction.
+func (tx *Tx) UndoAction() string {
+	if h := tx.s.history; len(h) > 0 {
+		return h[len(h)-1].action
+	}
+	return ""
+}
+
+// Undo reverts the newest change recorded by UpdateUndoable and removes
+// it from the history: changed todos are reset, created todos removed and
+// removed todos restored. Todos that were changed again since are skipped,
+// their number is returned as skipped.
+// Returns ErrNothingToUndo if the history is empty.
+func (s *Repository) Undo() (action string, skipped int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		action, skipped, err = tx.Undo()
+		return err
+	})
+	return action, skipped, err
+}
+
+// Undo is Repository.Undo within the transaction.
+func (tx *Tx) Undo() (action string, skipped int, err error) {
+	if !tx.writable {
+		return "", 0, ErrReadOnly
+	}
+	s := tx.s
+	if len(s.history) < 1 {
+		return "", 0, ErrNothingToUndo
+	}
+	e := s.history[len(s.history)-1]
+
+	now := time.Now()
+	var restored []Todo
+	var reset []int
+	var resetTodos []Todo
+	var removed []int
+	var events []Event
+	for _, c := range e.changes {
+		i := s.findByID(c.Todo.ID)
+		switch {
+		case c.Type == EventDeleted:
+			if _, taken := s.byUID[c.Todo.UID]; i >= 0 || (c.Todo.UID != "" && taken) {
+				skipped++
+				continue
+			}
+			t := c.Todo
+			t.Version++
+			if err := tx.index(t); err != nil {
+				return "", 0, err
+			}
+			restored = append(restored, t)
+			events = append(events, Event{Type: EventCreated, Todo: t, Time: now})
+		case i < 0 || s.todos[i].Version != c.Todo.Version:
+			// Removed or changed since.
+			skipped++
+		case c.Type == EventCreated:
+			tx.delete(c.Todo.ID)
+			removed = append(removed, i)
+			events = append(events, Event{Type: EventDeleted, Todo: s.todos[i], Time: now})
+		default:
+			t := c.Previous
+			t.Version = s.todos[i].Version + 1
+			if err := tx.index(t); err != nil {
+				return "", 0, err
+			}
+			reset = append(reset, i)
+			resetTodos = append(resetTodos, t)
+			events = append(events,
+				Event{Type: EventUpdated, Todo: t, Previous: s.todos[i], Time: now})
+			if t.Title != s.todos[i].Title {
+				events = append(events,
+					Event{Type: EventRenamed, Todo: t, Previous: s.todos[i], Time: now})
+			}
+		}
+	}
+	for j, i := range reset {
+		s.setTodo(i, resetTodos[j])
+	}
+	s.removeTodos(removed...)
+	s.appendTodos(restored...)
+	s.history = s.history[:len(s.history)-1]
+	tx.record(events...)
+	return e.action, skipped, nil
+}
@@ -0,0 +1,192 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+func newRepository(t *testing.T) *repository.Repository {
+	t.Helper()
+	r, err := repository.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = r.Close() })
+	return r
+}
+
+// snapshot returns all todos in order.
+func snapshot(t *testing.T, r *repository.Repository) []repository.Todo {
+	t.Helper()
+	all, err := r.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return all
+}
+
+func TestUndo(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	r := newRepository(t)
+	var ids []string
+	for i := range 3 {
+		id, err := r.Add("Todo "+strconv.Itoa(i), false, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	// Completing a recurring todo adds its next occurrence.
+	recurring, err := r.Get(ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	recurring.Recurrence, recurring.Due = "FREQ=DAILY", now
+	if err := r.Replace(recurring); err != nil {
+		t.Fatal(err)
+	}
+	before := snapshot(t, r)
+
+	for _, tt := range []struct {
+		action string
+		fn     func(tx *repository.Tx) error
+	}{
+		{"Mark done", func(tx *repository.Tx) error {
+			_, err := tx.SetDone(ids, true)
+			return err
+		}},
+		{"Tag #home", func(tx *repository.Tx) error {
+			_, err := tx.AddTag(ids, "home")
+			return err
+		}},
+		{"Move to list work", func(tx *repository.Tx) error {
+			_, err := tx.SetList(ids, "work")
+			return err
+		}},
+		{"Delete", func(tx *repository.Tx) error {
+			_, err := tx.RemoveAll(ids)
+			return err
+		}},
+	} {
+		t.Run(tt.action, func(t *testing.T) {
+			if err := r.UpdateUndoable(tt.action, tt.fn); err != nil {
+				t.Fatal(err)
+			}
+			if a := r.UndoAction(); a != tt.action {
+				t.Fatalf("undo action %q, want %q", a, tt.action)
+			}
+			action, skipped, err := r.Undo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if action != tt.action || skipped != 0 {
+				t.Errorf("undid %q skipping %d", action, skipped)
+			}
+			checkRestored(t, before, snapshot(t, r))
+			if a := r.UndoAction(); a != "" {
+				t.Errorf("undo action %q after undo", a)
+			}
+			before = snapshot(t, r)
+		})
+	}
+
+	if _, _, err := r.Undo(); !errors.Is(err, repository.ErrNothingToUndo) {
+		t.Fatalf("expected ErrNothingToUndo, got %v", err)
+	}
+	// Restored todos are searchable again.
+	found, err := r.Find(context.Background(), "todo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(ids) {
+		t.Errorf("found %d todos, want %d", len(found), len(ids))
+	}
+}
+
+// checkRestored compares all fields except the version,
+// which must be greater.
+func checkRestored(t *testing.T, before, after []repository.Todo) {
+	t.Helper()
+	if len(after) != len(before) {
+		t.Fatalf("%d todos, want %d", len(after), len(before))
+	}
+	for i, b := range before {
+		a := after[i]
+		if a.Version <= b.Version {
+			t.Errorf("todo %s: version %d not after %d", a.ID, a.Version, b.Version)
+		}
+		a.Version = b.Version
+		if a.Done != b.Done || a.List != b.List || a.Title != b.Title ||
+			a.Recurrence != b.Recurrence || a.Position != b.Position ||
+			!a.Completed.Equal(b.Completed) || !slices.Equal(a.Contexts, b.Contexts) {
+			t.Errorf("todo %d:\n got %#v\nwant %#v", i, a, b)
+		}
+	}
+}
+
+func TestUndoSkipsChangedTodos(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	r := newRepository(t)
+	a, _ := r.Add("A", false, now)
+	b, _ := r.Add("B", false, now)
+	err := r.UpdateUndoable("Mark done", func(tx *repository.Tx) error {
+		_, err := tx.SetDone([]string{a, b}, true)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Rename(b, "B renamed"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, skipped, err := r.Undo(); err != nil || skipped != 1 {
+		t.Fatalf("undo skipped %d: %v", skipped, err)
+	}
+	if td, _ := r.Get(a); td.Done {
+		t.Error("A still done")
+	}
+	if td, _ := r.Get(b); !td.Done || td.Title != "B renamed" {
+		t.Errorf("B changed by undo: %#v", td)
+	}
+}
+
+func TestUndoHistoryLimit(t *testing.T) {
+	r := newRepository(t)
+	id, _ := r.Add("A", false, time.Now())
+	for i := range repository.MaxHistory + 5 {
+		err := r.UpdateUndoable("Toggle "+strconv.Itoa(i), func(tx *repository.Tx) error {
+			_, err := tx.Toggle(id)
+			return err
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Changes without effect aren't recorded.
+	err := r.UpdateUndoable("Nothing", func(tx *repository.Tx) error {
+		_, err := tx.SetDone([]string{"unknown"}, true)
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n := 0
+	for ; ; n++ {
+		if _, _, err := r.Undo(); errors.Is(err, repository.ErrNothingToUndo) {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n != repository.MaxHistory {
+		t.Errorf("undid %d changes, want %d", n, repository.MaxHistory)
+	}
+}
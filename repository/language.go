@@ -0,0 +1,346 @@
+package repository
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/v2/analysis/char/asciifolding"
+	"github.com/blevesearch/bleve/v2/analysis/lang/de"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/token/porter"
+	"github.com/blevesearch/bleve/v2/analysis/token/stop"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/unicode"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/registry"
+)
+
+// Language selects how todo titles are analyzed for indexing and searching.
+// Language-specific analysis removes stop words, reduces words to their stem
+// and folds diacritics to ASCII, so "Äpfel" is found by "apfel".
+type Language string
+
+const (
+	// LanguageNone analyzes titles without stemming and folding.
+	LanguageNone      Language = ""
+	LanguageEnglish   Language = "en"
+	LanguageGerman    Language = "de"
+	LanguageUkrainian Language = "uk"
+)
+
+// Languages are the supported languages except LanguageNone.
+var Languages = []Language{LanguageEnglish, LanguageGerman, LanguageUkrainian}
+
+// Valid returns true if l is either LanguageNone or one of Languages.
+func (l Language) Valid() bool {
+	return l == LanguageNone || slices.Contains(Languages, l)
+}
+
+func (l Language) String() string {
+	switch l {
+	case LanguageNone:
+		return "None"
+	case LanguageEnglish:
+		return "English"
+	case LanguageGerman:
+		return "German"
+	case LanguageUkrainian:
+		return "Ukrainian"
+	}
+	return string(l)
+}
+
+// analyzer returns the name of the bleve analyzer of l.
+func (l Language) analyzer() string {
+	if l == LanguageNone {
+		return standard.Name
+	}
+	return "todo-" + string(l)
+}
+
+// documentType returns the bleve document type of todos in language l.
+// Documents of LanguageNone have no type and use the default mapping.
+func (l Language) documentType() string {
+	if l == LanguageNone {
+		return ""
+	}
+	return "todo-" + string(l)
+}
+
+// Names of the token filters registered by this package.
+const (
+	tokenFilterASCIIFolding       = "todo-asciifolding"
+	tokenFilterUkrainianNormalize = "todo-normalize-uk"
+	tokenFilterUkrainianStop      = "todo-stop-uk"
+	tokenFilterUkrainianStemmer   = "todo-stemmer-uk"
+)
+
+// languageTokenFilters are the token filters of the analyzer of each language
+// applied after the unicode tokenizer.
+var languageTokenFilters = map[Language][]string{
+	LanguageEnglish: {
+		en.PossessiveName, lowercase.Name, en.StopName, porter.Name,
+		tokenFilterASCIIFolding,
+	},
+	LanguageGerman: {
+		lowercase.Name, de.StopName, de.NormalizeName, de.LightStemmerName,
+		tokenFilterASCIIFolding,
+	},
+	LanguageUkrainian: {
+		lowercase.Name, tokenFilterUkrainianNormalize, tokenFilterUkrainianStop,
+		tokenFilterUkrainianStemmer, tokenFilterASCIIFolding,
+	},
+}
+
+func init() {
+	registry.RegisterTokenFilter(tokenFilterASCIIFolding,
+		func(map[string]any, *registry.Cache) (analysis.TokenFilter, error) {
+			return asciiFoldingFilter{chars: asciifolding.New()}, nil
+		})
+	registry.RegisterTokenFilter(tokenFilterUkrainianNormalize,
+		func(map[string]any, *registry.Cache) (analysis.TokenFilter, error) {
+			return ukrainianNormalizeFilter{}, nil
+		})
+	registry.RegisterTokenFilter(tokenFilterUkrainianStop,
+		func(map[string]any, *registry.Cache) (analysis.TokenFilter, error) {
+			words := analysis.NewTokenMap()
+			for _, w := range ukrainianStopWords {
+				words.AddToken(w)
+			}
+			return stop.NewStopTokensFilter(words), nil
+		})
+	registry.RegisterTokenFilter(tokenFilterUkrainianStemmer,
+		func(map[string]any, *registry.Cache) (analysis.TokenFilter, error) {
+			return ukrainianStemmerFilter{}, nil
+		})
+
+	for l, filterNames := range languageTokenFilters {
+		registry.RegisterAnalyzer(l.analyzer(),
+			func(_ map[string]any, cache *registry.Cache) (analysis.Analyzer, error) {
+				tokenizer, err := cache.TokenizerNamed(unicode.Name)
+				if err != nil {
+					return nil, fmt.Errorf("building tokenizer: %w", err)
+				}
+				filters := make([]analysis.TokenFilter, len(filterNames))
+				for i, name := range filterNames {
+					if filters[i], err = cache.TokenFilterNamed(name); err != nil {
+						return nil, fmt.Errorf("building token filter %q: %w", name, err)
+					}
+				}
+				return &analysis.DefaultAnalyzer{
+					Tokenizer:    tokenizer,
+					TokenFilters: filters,
+				}, nil
+			})
+	}
+}
+
+// asciiFoldingFilter folds the terms of tokens, unlike the
+// asciifolding char filter which is applied before stop words
+// and stemmers could match the original spelling.
+type asciiFoldingFilter struct {
+	chars *asciifolding.AsciiFoldingFilter
+}
+
+func (f asciiFoldingFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, t := range input {
+		t.Term = f.chars.Filter(t.Term)
+	}
+	return input
+}
+
+// analyzers builds the analyzers of search terms.
+var analyzers = registry.NewCache()
+
+// analyzeTerm returns the lower case term followed by the distinct
+// terms it's analyzed to by each of languages. Terms analyzed to
+// no token (stop words) or several tokens are skipped.
+func analyzeTerm(term string, languages []Language) []string {
+	terms := []string{strings.ToLower(term)}
+	for _, l := range languages {
+		if l == LanguageNone {
+			continue
+		}
+		a, err := analyzers.AnalyzerNamed(l.analyzer())
+		if err != nil {
+			// Unreachable, all analyzers are registered in init.
+			panic(fmt.Errorf("building analyzer %q: %w", l.analyzer(), err))
+		}
+		tokens := a.Analyze([]byte(term))
+		if len(tokens) == 1 && !slices.Contains(terms, string(tokens[0].Term)) {
+			terms = append(terms, string(tokens[0].Term))
+		}
+	}
+	return terms
+}
+
+// newIndexMapping returns the mapping of the search index
+// with a document type per language analyzing titles in that language.
+func newIndexMapping() mapping.IndexMapping {
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping.AddFieldMappingsAt("titleExact", newTitleExactMapping())
+	for _, l := range Languages {
+		title := bleve.NewTextFieldMapping()
+		title.Analyzer = l.analyzer()
+		doc := bleve.NewDocumentMapping()
+		doc.AddFieldMappingsAt("title", title)
+		doc.AddFieldMappingsAt("titleExact", newTitleExactMapping())
+		m.AddDocumentMapping(l.documentType(), doc)
+	}
+	return m
+}
+
+func newTitleExactMapping() *mapping.FieldMapping {
+	f := bleve.NewTextFieldMapping()
+	f.Analyzer = analyzerExact
+	f.Store = false
+	f.IncludeInAll = false
+	f.IncludeTermVectors = false
+	return f
+}
+
+// languageSettings are the languages todo titles are analyzed in.
+type languageSettings struct {
+	// Default is the language of lists without their own.
+	Default Language `json:"default"`
+	// Lists are the languages of lists by name.
+	Lists map[string]Language `json:"lists"`
+}
+
+// of returns the language of list.
+func (l languageSettings) of(list string) Language {
+	if lang, ok := l.Lists[list]; ok {
+		return lang
+	}
+	return l.Default
+}
+
+// used returns the distinct languages in use.
+func (l languageSettings) used() []Language {
+	used := []Language{l.Default}
+	for _, lang := range l.Lists {
+		if !slices.Contains(used, lang) {
+			used = append(used, lang)
+		}
+	}
+	return used
+}
+
+// indexedTodo is the search index document of a todo,
+// its type selects the mapping of the language of its list.
+type indexedTodo struct {
+	Todo
+	// PriorityRank sorts todos without a priority last.
+	PriorityRank int `json:"priorityRank"`
+	// TitleExact is the title indexed as a single term.
+	TitleExact string `json:"titleExact"`
+	language   Language
+}
+
+func newIndexedTodo(t Todo, l Language) indexedTodo {
+	return indexedTodo{
+		Todo:         t,
+		PriorityRank: priorityRank(t.Priority),
+		TitleExact:   t.Title,
+		language:     l,
+	}
+}
+
+func (d indexedTodo) BleveType() string { return d.language.documentType() }
+
+// document returns the search index document of t.
+// Must be called with s.lock held.
+func (s *Repository) document(t Todo) indexedTodo {
+	return newIndexedTodo(t, s.languages.of(t.List))
+}
+
+// DefaultLanguage returns the language of lists without their own.
+func (s *Repository) DefaultLanguage() (l Language) {
+	_ = s.View(func(tx *Tx) error { l = tx.DefaultLanguage(); return nil })
+	return l
+}
+
+// DefaultLanguage is Repository.DefaultLanguage within the transaction.
+func (tx *Tx) DefaultLanguage() Language { return tx.s.languages.Default }
+
+// ListLanguages returns the languages set for lists by list name.
+func (s *Repository) ListLanguages() (languages map[string]Language) {
+	_ = s.View(func(tx *Tx) error { languages = tx.ListLanguages(); return nil })
+	return languages
+}
+
+// ListLanguages is Repository.ListLanguages within the transaction.
+func (tx *Tx) ListLanguages() map[string]Language {
+	return maps.Clone(tx.s.languages.Lists)
+}
+
+// SetDefaultLanguage sets the language of lists without their own
+// and reindexes their todos.
+func (s *Repository) SetDefaultLanguage(l Language) error {
+	return s.Update(func(tx *Tx) error { return tx.SetDefaultLanguage(l) })
+}
+
+// SetDefaultLanguage is Repository.SetDefaultLanguage within the transaction.
+func (tx *Tx) SetDefaultLanguage(l Language) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	if !l.Valid() {
+		return fmt.Errorf("unsupported language: %q", l)
+	}
+	settings := tx.s.languages
+	settings.Default = l
+	return tx.setLanguages(settings)
+}
+
+// SetListLanguage sets the language of list and reindexes its todos.
+// LanguageNone resets list to the default language.
+func (s *Repository) SetListLanguage(list string, l Language) error {
+	return s.Update(func(tx *Tx) error { return tx.SetListLanguage(list, l) })
+}
+
+// SetListLanguage is Repository.SetListLanguage within the transaction.
+func (tx *Tx) SetListLanguage(list string, l Language) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	if !l.Valid() {
+		return fmt.Errorf("unsupported language: %q", l)
+	}
+	s := tx.s
+	settings := languageSettings{
+		Default: s.languages.Default,
+		Lists:   maps.Clone(s.languages.Lists),
+	}
+	if l == LanguageNone {
+		delete(settings.Lists, list)
+	} else {
+		if settings.Lists == nil {
+			settings.Lists = map[string]Language{}
+		}
+		settings.Lists[list] = l
+	}
+	return tx.setLanguages(settings)
+}
+
+// setLanguages sets the language settings and reindexes all todos
+// whose language changes.
+func (tx *Tx) setLanguages(settings languageSettings) error {
+	s := tx.s
+	prev := s.languages
+	s.languages = settings
+	for _, t := range s.todos {
+		if settings.of(t.List) != prev.of(t.List) {
+			if err := tx.index(t); err != nil {
+				return err
+			}
+		}
+	}
+	tx.changed = true
+	return nil
+}
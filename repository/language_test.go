@@ -0,0 +1,129 @@
+package repository_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// findTitles returns the sorted titles of the todos Find returns for query.
+func findTitles(t *testing.T, r *repository.Repository, query string) []string {
+	t.Helper()
+	found, err := r.Find(context.Background(), query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, td := range found {
+		titles = append(titles, td.Title)
+	}
+	slices.Sort(titles)
+	return titles
+}
+
+func TestFindLanguage(t *testing.T) {
+	for _, tt := range []struct {
+		language repository.Language
+		titles   []string
+		// expect are the titles found by each query.
+		expect map[string][]string
+	}{
+		{
+			language: repository.LanguageEnglish,
+			titles:   []string{"Walking the dogs", "Bake a cake"},
+			expect: map[string][]string{
+				"walk":  {"Walking the dogs"},
+				"dog":   {"Walking the dogs"},
+				"baked": {"Bake a cake"},
+				// Stop words.
+				"the": nil,
+			},
+		},
+		{
+			language: repository.LanguageGerman,
+			titles:   []string{"Äpfel kaufen", "Straße fegen", "Die Katze füttern"},
+			expect: map[string][]string{
+				"apfel":   {"Äpfel kaufen"},
+				"Äpfel":   {"Äpfel kaufen"},
+				"strasse": {"Straße fegen"},
+				"straße":  {"Straße fegen"},
+				"futtern": {"Die Katze füttern"},
+				"die":     nil,
+			},
+		},
+		{
+			language: repository.LanguageUkrainian,
+			titles:   []string{"Погодувати кішки", "Хліб та молоко"},
+			expect: map[string][]string{
+				"кішка":  {"Погодувати кішки"},
+				"кішки":  {"Погодувати кішки"},
+				"кішкам": {"Погодувати кішки"},
+				"хліба":  {"Хліб та молоко"},
+				"та":     nil,
+			},
+		},
+	} {
+		t.Run(string(tt.language), func(t *testing.T) {
+			r := newRepository(t)
+			if err := r.SetDefaultLanguage(tt.language); err != nil {
+				t.Fatal(err)
+			}
+			for _, title := range tt.titles {
+				if _, err := r.Add(title, false, time.Now()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for query, expect := range tt.expect {
+				if got := findTitles(t, r, query); !slices.Equal(got, expect) {
+					t.Errorf("%s: %q, want %q", query, got, expect)
+				}
+			}
+		})
+	}
+}
+
+func TestFindLanguageReindexed(t *testing.T) {
+	r := newRepository(t)
+	if _, err := r.Import([]repository.Todo{
+		{Title: "Straße fegen"},
+		{Title: "Погодувати кішки", List: "home"},
+	}, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	check := func(query string, expect ...string) {
+		t.Helper()
+		if got := findTitles(t, r, query); !slices.Equal(got, expect) {
+			t.Errorf("%s: %q, want %q", query, got, expect)
+		}
+	}
+
+	check("strasse")
+	check("кішкою")
+
+	if err := r.SetDefaultLanguage(repository.LanguageGerman); err != nil {
+		t.Fatal(err)
+	}
+	check("strasse", "Straße fegen")
+	check("кішкою")
+
+	// The list language takes precedence over the default language.
+	if err := r.SetListLanguage("home", repository.LanguageUkrainian); err != nil {
+		t.Fatal(err)
+	}
+	check("strasse", "Straße fegen")
+	check("кішкою", "Погодувати кішки")
+
+	if err := r.SetDefaultLanguage(repository.LanguageNone); err != nil {
+		t.Fatal(err)
+	}
+	check("strasse")
+	check("кішкою", "Погодувати кішки")
+
+	if err := r.SetListLanguage("home", repository.LanguageNone); err != nil {
+		t.Fatal(err)
+	}
+	check("кішкою")
+}
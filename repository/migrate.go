@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// migration upgrades a decoded store file from version from to from+1.
+// Migrations operate on the generic JSON representation to not depend
+// on the current Todo type.
+type migration struct {
+	from        int
+	description string
+	apply       func(store map[string]any) error
+}
+
+// migrations must be ordered by version and
+// the last migration must upgrade to SchemaVersion.
+var migrations = []migration{
+	{
+		from:        1,
+		description: "rename todo fields to lower camel case",
+		apply:       migrateV1ToV2,
+	},
+	{
+		from:        2,
+		description: "add positions for manual ordering",
+		apply:       migrateV2ToV3,
+	},
+	{
+		from:        3,
+		description: "add search languages",
+		apply:       migrateV3ToV4,
+	},
+	{
+		from:        4,
+		description: "add todo versions",
+		apply:       migrateV4ToV5,
+	},
+}
+
+// MigrationStep is a migration step applied by Migrate.
+type MigrationStep struct {
+	From, To    int
+	Description string
+}
+
+// Migrate upgrades the store file in dir to SchemaVersion, keeping a copy
+// of the original file suffixed with its version (e.g. "todos.json.v1.bak").
+// The search index is removed after migrating since its documents were created
+// from the old schema, Open will rebuild it.
+// If dryRun is true the migrations are applied in memory only.
+// Returns the steps that were (or would have been) applied.
+func Migrate(dir string, dryRun bool) ([]MigrationStep, error) {
+	p := filepath.Join(dir, FileStore)
+	original, err := os.ReadFile(p)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading store file: %w", err)
+	}
+
+	var store map[string]any
+	if err := json.Unmarshal(original, &store); err != nil {
+		return nil, fmt.Errorf("decoding store file: %w", err)
+	}
+	version, ok := store["version"].(float64)
+	if !ok || version < 1 || version != float64(int(version)) {
+		return nil, fmt.Errorf("invalid store schema version: %v", store["version"])
+	}
+	from := int(version)
+	if from > SchemaVersion {
+		return nil, fmt.Errorf(
+			"store schema version %d is newer than supported version %d",
+			from, SchemaVersion,
+		)
+	}
+
+	var steps []MigrationStep
+	for _, m := range migrations {
+		if m.from < from {
+			continue
+		}
+		if err := m.apply(store); err != nil {
+			return nil, fmt.Errorf("migrating from version %d to %d: %w",
+				m.from, m.from+1, err)
+		}
+		store["version"] = m.from + 1
+		steps = append(steps, MigrationStep{
+			From: m.from, To: m.from + 1, Description: m.description,
+		})
+	}
+	if dryRun || len(steps) < 1 {
+		return steps, nil
+	}
+
+	migrated, err := json.Marshal(store)
+	if err != nil {
+		return nil, fmt.Errorf("encoding migrated store file: %w", err)
+	}
+	backup := fmt.Sprintf("%s.v%d.bak", p, from)
+	if err := WriteFileAtomic(backup, original); err != nil {
+		return nil, fmt.Errorf("writing store file backup: %w", err)
+	}
+	if err := WriteFileAtomic(p, migrated); err != nil {
+		return nil, err
+	}
+	if err := os.RemoveAll(filepath.Join(dir, DirIndex)); err != nil {
+		return nil, fmt.Errorf("removing outdated bleve index: %w", err)
+	}
+	return steps, nil
+}
+
+// storeTodos returns the todo objects of a decoded store file.
+func storeTodos(store map[string]any) ([]map[string]any, error) {
+	raw, ok := store["todos"].([]any)
+	if !ok {
+		if store["todos"] == nil {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("todos: expected array, got %T", store["todos"])
+	}
+	todos := make([]map[string]any, len(raw))
+	for i := range raw {
+		if todos[i], ok = raw[i].(map[string]any); !ok {
+			return nil, fmt.Errorf("todos[%d]: expected object, got %T", i, raw[i])
+		}
+	}
+	return todos, nil
+}
+
+func migrateV1ToV2(store map[string]any) error {
+	todos, err := storeTodos(store)
+	if err != nil {
+		return err
+	}
+	renames := map[string]string{
+		"ID":        "id",
+		"List":      "list",
+		"Title":     "title",
+		"Done":      "done",
+		"Created":   "created",
+		"Completed": "completed",
+		"Due":       "due",
+		"Priority":  "priority",
+		"Parent":    "parent",
+		"UID":       "uid",
+		"Projects":  "projects",
+		"Contexts":  "contexts",
+		"Meta":      "meta",
+	}
+	for _, t := range todos {
+		for from, to := range renames {
+			v, ok := t[from]
+			if !ok {
+				continue
+			}
+			delete(t, from)
+			if v != nil {
+				t[to] = v
+			}
+		}
+	}
+	return nil
+}
+
+// migrateV2ToV3 positions the todos newest first, the order they were listed in.
+func migrateV2ToV3(store map[string]any) error {
+	todos, err := storeTodos(store)
+	if err != nil {
+		return err
+	}
+	pos := ""
+	for i := len(todos) - 1; i >= 0; i-- {
+		if pos, err = positionBetween(pos, ""); err != nil {
+			return err
+		}
+		todos[i]["position"] = pos
+	}
+	return nil
+}
+
+// migrateV3ToV4 adds the language settings, all todos keep being
+// analyzed language-neutrally. The index is rebuilt with
+// the mapping of the language analyzers.
+func migrateV3ToV4(store map[string]any) error {
+	store["languages"] = map[string]any{
+		"default": string(LanguageNone),
+		"lists":   map[string]any{},
+	}
+	return nil
+}
+
+// migrateV4ToV5 sets the version of all todos without one to 1,
+// the version of new todos, since version 0 is never valid.
+func migrateV4ToV5(store map[string]any) error {
+	todos, err := storeTodos(store)
+	if err != nil {
+		return err
+	}
+	for _, t := range todos {
+		if v, _ := t["version"].(float64); v < 1 {
+			t["version"] = 1
+		}
+	}
+	return nil
+}
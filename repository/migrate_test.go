@@ -0,0 +1,179 @@
+package repository_test
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// setupStore copies the fixture into a new data directory
+// and returns the directory and the fixture contents.
+func setupStore(t *testing.T, fixture string) (dir string, original []byte) {
+	t.Helper()
+	original, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir = t.TempDir()
+	p := filepath.Join(dir, repository.FileStore)
+	if err := os.WriteFile(p, original, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return dir, original
+}
+
+func TestMigrate(t *testing.T) {
+	for from := 1; from < repository.SchemaVersion; from++ {
+		t.Run(fmt.Sprintf("v%d", from), func(t *testing.T) {
+			dir, original := setupStore(t, fmt.Sprintf("v%d.json", from))
+			p := filepath.Join(dir, repository.FileStore)
+			backup := fmt.Sprintf("%s.v%d.bak", p, from)
+
+			steps, err := repository.Migrate(dir, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSteps(t, steps, from)
+			if b, err := os.ReadFile(p); err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(b, original) {
+				t.Fatal("dry run changed the store file")
+			}
+			if _, err := os.Stat(backup); !os.IsNotExist(err) {
+				t.Fatalf("dry run wrote a backup: %v", err)
+			}
+
+			steps, err = repository.Migrate(dir, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checkSteps(t, steps, from)
+			if b, err := os.ReadFile(backup); err != nil {
+				t.Fatal(err)
+			} else if !bytes.Equal(b, original) {
+				t.Fatal("backup differs from the original store file")
+			}
+			if steps, err := repository.Migrate(dir, false); err != nil {
+				t.Fatal(err)
+			} else if len(steps) > 0 {
+				t.Fatalf("migrated store was migrated again: %v", steps)
+			}
+
+			r, err := repository.Open(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+			checkFixtureTodos(t, r)
+		})
+	}
+}
+
+func checkSteps(t *testing.T, steps []repository.MigrationStep, from int) {
+	t.Helper()
+	if len(steps) != repository.SchemaVersion-from {
+		t.Fatalf("%d steps, want %d: %v", len(steps), repository.SchemaVersion-from, steps)
+	}
+	for i, s := range steps {
+		if s.From != from+i || s.To != from+i+1 || s.Description == "" {
+			t.Errorf("step %d: %+v", i, s)
+		}
+	}
+}
+
+// checkFixtureTodos checks the todos all fixtures describe.
+func checkFixtureTodos(t *testing.T, r *repository.Repository) {
+	t.Helper()
+	if n := r.Len(); n != 3 {
+		t.Fatalf("%d todos, want 3", n)
+	}
+	all, err := r.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, td := range all {
+		ids = append(ids, td.ID)
+	}
+	// Newest first, the order todos were listed in before manual ordering.
+	if want := []string{"3", "2", "1"}; !slices.Equal(ids, want) {
+		t.Errorf("order %v, want %v", ids, want)
+	}
+
+	milk, err := r.Get("1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if milk.Title != "Buy milk" || milk.Priority != 'A' ||
+		!milk.Due.Equal(time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)) ||
+		!slices.Equal(milk.Contexts, []string{"shop"}) {
+		t.Errorf("todo 1: %#v", milk)
+	}
+	rent, err := r.Get("2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rent.Done || rent.Parent != "1" ||
+		!rent.Completed.Equal(time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC)) {
+		t.Errorf("todo 2: %#v", rent)
+	}
+	report, err := r.Get("3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.List != "work" || report.UID != "report@example.com" ||
+		!slices.Equal(report.Projects, []string{"q1"}) || report.Meta["k"] != "v" {
+		t.Errorf("todo 3: %#v", report)
+	}
+
+	for _, td := range all {
+		if td.Version != 1 {
+			t.Errorf("todo %s: version %d, want 1", td.ID, td.Version)
+		}
+	}
+
+	if l := r.DefaultLanguage(); l != repository.LanguageNone {
+		t.Errorf("default language %q, want none", l)
+	}
+	// New todos continue the ID counter.
+	id, err := r.Add("New", false, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "4" {
+		t.Errorf("new ID %q, want 4", id)
+	}
+}
+
+func TestMigrateErrors(t *testing.T) {
+	for _, store := range []string{
+		`{"version": 0, "todos": []}`,
+		`{"todos": []}`,
+		`{"version": 1.5, "todos": []}`,
+		fmt.Sprintf(`{"version": %d, "todos": []}`, repository.SchemaVersion+1),
+		`{"version": 1, "todos": {}}`,
+		`not json`,
+	} {
+		dir := t.TempDir()
+		p := filepath.Join(dir, repository.FileStore)
+		if err := os.WriteFile(p, []byte(store), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := repository.Migrate(dir, true); err == nil {
+			t.Errorf("Migrate(%s): expected error", store)
+		}
+	}
+}
+
+func TestMigrateNoStore(t *testing.T) {
+	steps, err := repository.Migrate(t.TempDir(), false)
+	if err != nil || len(steps) > 0 {
+		t.Fatalf("Migrate without store: %v, %v", steps, err)
+	}
+}
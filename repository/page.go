@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Page is a page of todos.
+type Page struct {
+	Todos []Todo
+	// Total is the number of todos on all pages.
+	Total int
+	// Next and Prev are the cursors of the next and previous page,
+	// empty if there is none.
+	Next, Prev string
+	// Highlights are the titles of search results split into matching
+	// and non-matching segments by todo ID.
+	Highlights map[string][]Segment
+	// Facets are the counts of all search results by status, tags,
+	// priority and creation date.
+	Facets []Facet
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursors are opaque to callers. Pages of All are keyed by position
+// ("a" followed by the position of the last todo of the previous page,
+// "b" followed by the position of the first todo of the next page),
+// pages of search results by offset ("o" followed by the offset)
+// since bleve only supports From/Size paging for relevance ordering.
+
+func encodeCursor(kind byte, value string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(string(kind) + value))
+}
+
+func decodeCursor(cursor string) (kind byte, value string, err error) {
+	if cursor == "" {
+		return 0, "", nil
+	}
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(b) < 2 {
+		return 0, "", ErrInvalidCursor
+	}
+	return b[0], string(b[1:]), nil
+}
+
+// AllPage returns up to limit todos of All starting at cursor,
+// which is empty for the first page.
+// Returns ErrInvalidCursor if cursor is malformed.
+func (s *Repository) AllPage(cursor string, limit int) (p Page, err error) {
+	err = s.View(func(tx *Tx) error {
+		p, err = tx.AllPage(cursor, limit)
+		return err
+	})
+	return p, err
+}
+
+// AllPage is Repository.AllPage within the transaction.
+func (tx *Tx) AllPage(cursor string, limit int) (Page, error) {
+	kind, pos, err := decodeCursor(cursor)
+	if err != nil {
+		return Page{}, err
+	}
+
+	s := tx.s
+	todos := s.sorted(false)
+	// Positions are unique, so they can be used as keys.
+	cmp := func(i int, pos string) int { return strings.Compare(s.todos[i].Position, pos) }
+	start, end := 0, 0
+	switch kind {
+	case 0:
+	case 'a':
+		start, _ = slices.BinarySearchFunc(todos, pos, cmp)
+		if start < len(todos) && s.todos[todos[start]].Position == pos {
+			start++
+		}
+	case 'b':
+		end, _ = slices.BinarySearchFunc(todos, pos, cmp)
+		start = max(end-limit, 0)
+	default:
+		return Page{}, ErrInvalidCursor
+	}
+	if kind != 'b' {
+		end = min(start+limit, len(todos))
+	}
+
+	p := Page{Todos: s.todosAt(todos[start:end]), Total: len(todos)}
+	if end < len(todos) && end > 0 {
+		p.Next = encodeCursor('a', s.todos[todos[end-1]].Position)
+	}
+	switch {
+	case start == 0:
+	case start < len(todos):
+		p.Prev = encodeCursor('b', s.todos[todos[start]].Position)
+	default:
+		// The cursor is at or past the end, for example because the
+		// following todos were removed. The previous page ends after
+		// the last todo, which is before any greater position.
+		p.Prev = encodeCursor('b', s.todos[todos[start-1]].Position+"\x00")
+	}
+	return p, nil
+}
+
+// FindPage returns up to limit todos of Find in the given order
+// starting at cursor, which is empty for the first page,
+// with highlights and facets.
+// Returns ErrInvalidCursor if cursor is malformed, a *QueryError
+// if term is invalid and ctx.Err() if ctx is canceled.
+func (s *Repository) FindPage(
+	ctx context.Context, term string, sort SortOrder, cursor string, limit int,
+) (p Page, err error) {
+	err = s.View(func(tx *Tx) error {
+		p, err = tx.FindPage(ctx, term, sort, cursor, limit)
+		return err
+	})
+	return p, err
+}
+
+// FindPage is Repository.FindPage within the transaction.
+func (tx *Tx) FindPage(
+	ctx context.Context, term string, sort SortOrder, cursor string, limit int,
+) (Page, error) {
+	kind, value, err := decodeCursor(cursor)
+	if err != nil {
+		return Page{}, err
+	}
+	offset := 0
+	switch kind {
+	case 0:
+	case 'o':
+		if offset, err = strconv.Atoi(value); err != nil || offset < 0 {
+			return Page{}, ErrInvalidCursor
+		}
+	default:
+		return Page{}, ErrInvalidCursor
+	}
+	p, err := tx.s.search(ctx, term, searchOptions{
+		sort:      sort,
+		from:      offset,
+		size:      limit,
+		highlight: true,
+		facets:    true,
+	})
+	if err != nil {
+		return Page{}, err
+	}
+	if offset+limit < p.Total {
+		p.Next = encodeCursor('o', strconv.Itoa(offset+limit))
+	}
+	if offset > 0 {
+		// The offset may be past the end if results were removed.
+		p.Prev = encodeCursor('o', strconv.Itoa(max(min(offset, p.Total)-limit, 0)))
+	}
+	return p, nil
+}
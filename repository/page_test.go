@@ -0,0 +1,148 @@
+package repository_test
+
+import (
+	"context"
+	"encoding/base64"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// newPagedRepository returns a repository with n todos
+// and their IDs in the order of All.
+func newPagedRepository(t *testing.T, n int) (*repository.Repository, []string) {
+	t.Helper()
+	r := newRepository(t)
+	for i := range n {
+		if _, err := r.Add("Todo "+strconv.Itoa(i), false, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r, ids(snapshot(t, r))
+}
+
+func TestAllPage(t *testing.T) {
+	r, all := newPagedRepository(t, 5)
+	page := func(cursor string, want ...string) repository.Page {
+		t.Helper()
+		p, err := r.AllPage(cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(p.Todos); !slices.Equal(got, want) {
+			t.Fatalf("page %q: %v, want %v", cursor, got, want)
+		}
+		return p
+	}
+
+	p1 := page("", all[0], all[1])
+	if p1.Prev != "" {
+		t.Errorf("first page has previous page %q", p1.Prev)
+	}
+	p2 := page(p1.Next, all[2], all[3])
+	p3 := page(p2.Next, all[4])
+	if p3.Next != "" {
+		t.Errorf("last page has next page %q", p3.Next)
+	}
+	page(p3.Prev, all[2], all[3])
+	page(p2.Prev, all[0], all[1])
+
+	// A cursor past the last position returns an empty page
+	// whose previous page is the last page.
+	past := base64.RawURLEncoding.EncodeToString([]byte("azzzz"))
+	p := page(past)
+	if p.Next != "" || p.Prev == "" {
+		t.Fatalf("page past the end: next %q, prev %q", p.Next, p.Prev)
+	}
+	page(p.Prev, all[3], all[4])
+
+	// The todos after a cursor were removed.
+	if err := r.Remove(all[4]); err != nil {
+		t.Fatal(err)
+	}
+	p = page(p2.Next)
+	if p.Next != "" || p.Prev == "" {
+		t.Fatalf("page after removed todos: next %q, prev %q", p.Next, p.Prev)
+	}
+	page(p.Prev, all[2], all[3])
+
+	// The todo a cursor points at was removed.
+	if err := r.Remove(all[1]); err != nil {
+		t.Fatal(err)
+	}
+	p = page(p1.Next, all[2], all[3])
+	page(p.Prev, all[0])
+
+	empty, _ := newPagedRepository(t, 0)
+	p, err := empty.AllPage("", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Todos) != 0 || p.Next != "" || p.Prev != "" {
+		t.Fatalf("empty repository: %+v", p)
+	}
+	if p, err = empty.AllPage(past, 2); err != nil {
+		t.Fatal(err)
+	} else if len(p.Todos) != 0 || p.Next != "" || p.Prev != "" {
+		t.Fatalf("empty repository past the end: %+v", p)
+	}
+}
+
+func TestFindPage(t *testing.T) {
+	r, _ := newPagedRepository(t, 5)
+	page := func(cursor string, want int) repository.Page {
+		t.Helper()
+		p, err := r.FindPage(context.Background(), "todo", repository.SortNewest, cursor, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(p.Todos) != want {
+			t.Fatalf("page %q: %d todos, want %d", cursor, len(p.Todos), want)
+		}
+		return p
+	}
+
+	var found []string
+	p1 := page("", 2)
+	p2 := page(p1.Next, 2)
+	p3 := page(p2.Next, 1)
+	for _, p := range []repository.Page{p1, p2, p3} {
+		found = append(found, ids(p.Todos)...)
+	}
+	if len(slices.Compact(slices.Sorted(slices.Values(found)))) != 5 {
+		t.Fatalf("duplicate results %v", found)
+	}
+	if p1.Prev != "" || p3.Next != "" {
+		t.Errorf("first page prev %q, last page next %q", p1.Prev, p3.Next)
+	}
+	if got := ids(page(p3.Prev, 2).Todos); !slices.Equal(got, ids(p2.Todos)) {
+		t.Errorf("previous of last page %v, want %v", got, ids(p2.Todos))
+	}
+
+	// An offset past the end returns an empty page
+	// whose previous page is the last page.
+	past := base64.RawURLEncoding.EncodeToString([]byte("o10"))
+	p := page(past, 0)
+	if p.Next != "" || p.Prev == "" {
+		t.Fatalf("page past the end: next %q, prev %q", p.Next, p.Prev)
+	}
+	page(p.Prev, 2)
+
+	// The results after a cursor were removed.
+	if _, err := r.RemoveAll(ids(p3.Todos)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.RemoveAll(ids(p2.Todos)[1:]); err != nil {
+		t.Fatal(err)
+	}
+	p = page(p2.Next, 0)
+	if p.Next != "" || p.Prev == "" {
+		t.Fatalf("page after removed results: next %q, prev %q", p.Next, p.Prev)
+	}
+	if got := ids(page(p.Prev, 2).Todos); !slices.Equal(got, found[1:3]) {
+		t.Errorf("previous page %v, want %v", got, found[1:3])
+	}
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Positions are fractional indexing keys (see
+// https://observablehq.com/@dgreensp/implementing-fractional-indexing)
+// compared as plain strings. A key between any two keys can always be
+// generated, so moving a todo only changes the position of that todo.
+//
+// A key consists of an integer part, whose length is encoded in its
+// first character ('a' to 'z' for increasingly large positive and
+// 'Z' to 'A' for increasingly small negative integers), followed by
+// an optional fractional part without trailing zeros.
+
+const positionDigits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// positionSmallestInteger is the smallest integer part,
+// no key can be generated before it.
+var positionSmallestInteger = "A" + strings.Repeat(positionDigits[:1], 26)
+
+var errPositionOutOfRange = errors.New("position out of range")
+
+// positionBetween returns a key between a and b.
+// An empty a means before b, an empty b means after a,
+// both empty returns the first key of an empty list.
+func positionBetween(a, b string) (string, error) {
+	if a != "" {
+		if err := validatePosition(a); err != nil {
+			return "", err
+		}
+	}
+	if b != "" {
+		if err := validatePosition(b); err != nil {
+			return "", err
+		}
+	}
+	switch {
+	case a != "" && b != "" && a >= b:
+		return "", fmt.Errorf("position %q is not before %q", a, b)
+	case a == "" && b == "":
+		return "a" + positionDigits[:1], nil
+	case a == "":
+		ib, _ := positionInteger(b)
+		fb := b[len(ib):]
+		if ib == positionSmallestInteger {
+			return ib + midpoint("", fb), nil
+		}
+		if ib < b {
+			return ib, nil
+		}
+		res, ok := decrementInteger(ib)
+		if !ok {
+			return "", errPositionOutOfRange
+		}
+		return res, nil
+	case b == "":
+		ia, _ := positionInteger(a)
+		fa := a[len(ia):]
+		if i, ok := incrementInteger(ia); ok {
+			return i, nil
+		}
+		return ia + midpoint(fa, ""), nil
+	}
+	ia, _ := positionInteger(a)
+	fa := a[len(ia):]
+	ib, _ := positionInteger(b)
+	fb := b[len(ib):]
+	if ia == ib {
+		return ia + midpoint(fa, fb), nil
+	}
+	i, ok := incrementInteger(ia)
+	if !ok {
+		return "", errPositionOutOfRange
+	}
+	if i < b {
+		return i, nil
+	}
+	return ia + midpoint(fa, ""), nil
+}
+
+// midpoint returns a fraction between a and b, an empty b means infinity.
+// Neither a nor b may have trailing zeros.
+func midpoint(a, b string) string {
+	if b != "" {
+		// Keep the common prefix, treating a as padded with zeros.
+		n := 0
+		for n < len(b) && digitAt(a, n) == b[n] {
+			n++
+		}
+		if n > 0 {
+			return b[:n] + midpoint(a[min(n, len(a)):], b[n:])
+		}
+	}
+	digitA := 0
+	if a != "" {
+		digitA = strings.IndexByte(positionDigits, a[0])
+	}
+	digitB := len(positionDigits)
+	if b != "" {
+		digitB = strings.IndexByte(positionDigits, b[0])
+	}
+	if digitB-digitA > 1 {
+		return string(positionDigits[(digitA+digitB+1)/2])
+	}
+	if len(b) > 1 {
+		return b[:1]
+	}
+	rest := ""
+	if a != "" {
+		rest = a[1:]
+	}
+	return string(positionDigits[digitA]) + midpoint(rest, "")
+}
+
+// digitAt returns the digit at index i of s padded with zeros.
+func digitAt(s string, i int) byte {
+	if i < len(s) {
+		return s[i]
+	}
+	return positionDigits[0]
+}
+
+func integerLength(head byte) (int, bool) {
+	switch {
+	case head >= 'a' && head <= 'z':
+		return int(head-'a') + 2, true
+	case head >= 'A' && head <= 'Z':
+		return int('Z'-head) + 2, true
+	}
+	return 0, false
+}
+
+// positionInteger returns the integer part of key.
+func positionInteger(key string) (string, error) {
+	n, ok := integerLength(key[0])
+	if !ok || n > len(key) {
+		return "", fmt.Errorf("invalid position: %q", key)
+	}
+	return key[:n], nil
+}
+
+func validatePosition(key string) error {
+	if key == positionSmallestInteger {
+		return fmt.Errorf("invalid position: %q", key)
+	}
+	i, err := positionInteger(key)
+	if err != nil {
+		return err
+	}
+	for j := range len(key) {
+		if strings.IndexByte(positionDigits, key[j]) < 0 {
+			return fmt.Errorf("invalid position: %q", key)
+		}
+	}
+	if f := key[len(i):]; f != "" && f[len(f)-1] == positionDigits[0] {
+		return fmt.Errorf("invalid position: %q", key)
+	}
+	return nil
+}
+
+// incrementInteger returns ok=false if x is the largest integer.
+func incrementInteger(x string) (string, bool) {
+	head, digits := x[0], []byte(x[1:])
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := strings.IndexByte(positionDigits, digits[i]) + 1
+		if d < len(positionDigits) {
+			digits[i] = positionDigits[d]
+			return string(head) + string(digits), true
+		}
+		digits[i] = positionDigits[0]
+	}
+	// Carry over into the length.
+	switch head {
+	case 'Z':
+		return "a" + positionDigits[:1], true
+	case 'z':
+		return "", false
+	}
+	head++
+	if head > 'a' {
+		digits = append(digits, positionDigits[0])
+	} else {
+		digits = digits[:len(digits)-1]
+	}
+	return string(head) + string(digits), true
+}
+
+// decrementInteger returns ok=false if x is the smallest integer.
+func decrementInteger(x string) (string, bool) {
+	last := positionDigits[len(positionDigits)-1]
+	head, digits := x[0], []byte(x[1:])
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := strings.IndexByte(positionDigits, digits[i]) - 1
+		if d >= 0 {
+			digits[i] = positionDigits[d]
+			return string(head) + string(digits), true
+		}
+		digits[i] = last
+	}
+	// Borrow from the length.
+	switch head {
+	case 'a':
+		return "Z" + string(last), true
+	case 'A':
+		return "", false
+	}
+	head--
+	if head < 'Z' {
+		digits = append(digits, last)
+	} else {
+		digits = digits[:len(digits)-1]
+	}
+	return string(head) + string(digits), true
+}
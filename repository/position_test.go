@@ -0,0 +1,296 @@
+package repository_test
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+func ids(todos []repository.Todo) []string {
+	l := make([]string, len(todos))
+	for i, t := range todos {
+		l[i] = t.ID
+	}
+	return l
+}
+
+func TestOrder(t *testing.T) {
+	r := newRepository(t)
+	add := func(title string) string {
+		t.Helper()
+		id, err := r.Add(title, false, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+	check := func(want ...string) {
+		t.Helper()
+		all, err := r.All()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(all); !slices.Equal(got, want) {
+			t.Fatalf("order %v, want %v", got, want)
+		}
+		// The result must not share the cache.
+		if len(all) > 0 {
+			all[0].ID = "modified"
+		}
+	}
+
+	a, b, c := add("A"), add("B"), add("C")
+	check(c, b, a)
+	// New todos are added before a todo moved away from the top.
+	if _, err := r.Move(c, a, ""); err != nil {
+		t.Fatal(err)
+	}
+	check(b, a, c)
+	d := add("D")
+	check(d, b, a, c)
+	// And before a todo moved to the top.
+	if _, err := r.Move(a, "", d); err != nil {
+		t.Fatal(err)
+	}
+	e := add("E")
+	check(e, a, d, b, c)
+	// And after the first todo was removed.
+	if err := r.Remove(e); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.RemoveAll([]string{a}); err != nil {
+		t.Fatal(err)
+	}
+	f := add("F")
+	check(f, d, b, c)
+	// Archived todos are listed separately.
+	if _, err := r.SetDone([]string{d}, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Archive([]string{d}); err != nil {
+		t.Fatal(err)
+	}
+	check(f, b, c)
+	archived, err := r.Archived()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(archived); !slices.Equal(got, []string{d}) {
+		t.Fatalf("archived %v", got)
+	}
+}
+
+func TestOrderConcurrent(t *testing.T) {
+	r := newRepository(t)
+	const writers, adds = 4, 25
+	var wg sync.WaitGroup
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range adds {
+				if _, err := r.Add(fmt.Sprintf("%d-%d", w, i), false, time.Now()); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range adds {
+				if _, err := r.AllPage("", 10); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	all, err := r.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != writers*adds {
+		t.Fatalf("%d todos, want %d", len(all), writers*adds)
+	}
+	// Every todo was added before all existing ones, so IDs descend.
+	for i := 1; i < len(all); i++ {
+		prev, _ := strconv.ParseUint(all[i-1].ID, 16, 64)
+		cur, _ := strconv.ParseUint(all[i].ID, 16, 64)
+		if prev <= cur {
+			t.Fatalf("todo %s listed before %s", all[i-1].ID, all[i].ID)
+		}
+	}
+}
+
+// benchmarkSize is the number of todos the benchmarks run with.
+const benchmarkSize = 100_000
+
+// newBenchmarkRepository returns a repository with n todos.
+func newBenchmarkRepository(b *testing.B, n int) *repository.Repository {
+	b.Helper()
+	r, err := repository.NewRepository()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { _ = r.Close() })
+	todos := make([]repository.Todo, n)
+	for i := range todos {
+		todos[i] = repository.Todo{Title: "Todo " + strconv.Itoa(i)}
+	}
+	if _, err := r.Import(todos, time.Now()); err != nil {
+		b.Fatal(err)
+	}
+	return r
+}
+
+// readLoad reads the first page in parallel until stop is called.
+func readLoad(b *testing.B, r *repository.Repository, readers int) (stop func()) {
+	b.Helper()
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	for range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if _, err := r.AllPage("", 50); err != nil {
+					b.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	return func() { close(done); wg.Wait() }
+}
+
+func BenchmarkAdd(b *testing.B) {
+	r := newBenchmarkRepository(b, benchmarkSize)
+	stop := readLoad(b, r, 4)
+	defer stop()
+	b.ResetTimer()
+	for i := range b.N {
+		if _, err := r.Add("New "+strconv.Itoa(i), false, time.Now()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAllPage(b *testing.B) {
+	r := newBenchmarkRepository(b, benchmarkSize)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := r.AllPage("", 50); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkAllPageWhileAdding(b *testing.B) {
+	r := newBenchmarkRepository(b, benchmarkSize)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(10 * time.Millisecond):
+			}
+			if _, err := r.Add("New "+strconv.Itoa(i), false, time.Now()); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	}()
+	defer func() { close(done); <-stopped }()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := r.AllPage("", 50); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkFind(b *testing.B) {
+	r := newBenchmarkRepository(b, benchmarkSize)
+	for _, bb := range []struct{ name, term string }{
+		// Maps every todo found back to the repository.
+		{"all", "todo"},
+		{"few", "1234"},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			for range b.N {
+				if _, err := r.Find(context.Background(), bb.term); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkReadWhileWriting reads in parallel while a writer
+// renames todos without pause.
+func BenchmarkReadWhileWriting(b *testing.B) {
+	r := newBenchmarkRepository(b, benchmarkSize)
+	first, err := r.AllPage("", 50)
+	if err != nil {
+		b.Fatal(err)
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			id := first.Todos[i%len(first.Todos)].ID
+			if _, err := r.Rename(id, "Renamed "+strconv.Itoa(i)); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	}()
+	defer func() { close(done); <-stopped }()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			var err error
+			switch i % 3 {
+			case 0:
+				_, err = r.AllPage("", 50)
+			case 1:
+				_, err = r.Get(first.Todos[i%len(first.Todos)].ID)
+			default:
+				_, err = r.FindPage(context.Background(), "1234", repository.SortRelevance, "", 50)
+			}
+			if err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
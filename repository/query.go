@@ -0,0 +1,385 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// Search query syntax:
+//
+//	cat food       todos matching both terms (prefix or fuzzy)
+//	feeed~2        fuzzy match with an edit distance of up to 2
+//	"feed the"     phrase
+//	-milk          exclude todos matching the term, phrase or qualifier
+//	cat OR dog     either side, AND binds tighter than OR
+//	cat AND dog    same as "cat dog"
+//	done:true      done todos, done:false for open todos
+//	tag:home       todos with the context or project tag "home"
+//	list:work      todos in the list "work"
+//	priority:A     todos of priority A
+//	due:<2026-11-01, due:<=, due:>, due:>=, due:2026-11-01
+//	created:>=2026-10-01 and the same comparisons as due:
+//	note:call      other words followed by a colon are terms: note call
+//
+// Terms and phrases are also analyzed in the languages of the lists
+// (see Language) to match stemmed and folded titles.
+// Terms shorter than MinFuzzyTermLength and excluded terms
+// are only matched by prefix unless an edit distance is given.
+
+// MaxFuzziness is the maximum edit distance supported by bleve.
+const MaxFuzziness = 2
+
+// DefaultFuzziness is the edit distance terms are matched with by default.
+const DefaultFuzziness = 1
+
+// MinFuzzyTermLength is the minimum length of a term to be matched fuzzily.
+const MinFuzzyTermLength = 3
+
+// QueryError is returned for invalid search queries.
+type QueryError struct {
+	// Pos is the byte offset of the error in the query.
+	Pos int
+	Msg string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Pos+1, e.Msg)
+}
+
+// QueryOptions configures ParseQuery.
+type QueryOptions struct {
+	// Fuzziness is the default edit distance of terms (see DefaultFuzziness).
+	Fuzziness int
+	// Languages are the languages terms and phrases are analyzed in
+	// in addition to LanguageNone.
+	Languages []Language
+}
+
+// queryFields are the supported field qualifiers.
+var queryFields = []string{"done", "tag", "list", "priority", "due", "created"}
+
+type queryTokenKind int8
+
+const (
+	queryTokenTerm queryTokenKind = iota
+	queryTokenPhrase
+	queryTokenAnd
+	queryTokenOr
+)
+
+type queryToken struct {
+	kind   queryTokenKind
+	pos    int
+	negate bool
+	// field is the qualifier of a term or phrase, e.g. "tag" for "tag:home".
+	field string
+	text  string
+}
+
+// ParseQuery parses a search query.
+// Returns a *QueryError if q is invalid.
+func ParseQuery(q string, opts QueryOptions) (query.Query, error) {
+	tokens, err := lexQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) < 1 {
+		return nil, &QueryError{Pos: 0, Msg: "empty query"}
+	}
+
+	// Split into groups of AND-ed clauses separated by OR.
+	var groups [][]queryToken
+	var group []queryToken
+	expectOperand := true
+	for _, t := range tokens {
+		switch t.kind {
+		case queryTokenAnd, queryTokenOr:
+			op := "AND"
+			if t.kind == queryTokenOr {
+				op = "OR"
+			}
+			if expectOperand {
+				return nil, &QueryError{Pos: t.pos, Msg: op + " without a term before it"}
+			}
+			expectOperand = true
+			if t.kind == queryTokenOr {
+				groups, group = append(groups, group), nil
+			}
+		default:
+			expectOperand = false
+			group = append(group, t)
+		}
+	}
+	if last := tokens[len(tokens)-1]; expectOperand {
+		return nil, &QueryError{Pos: last.pos, Msg: last.text + " without a term after it"}
+	}
+	groups = append(groups, group)
+
+	disjuncts := make([]query.Query, len(groups))
+	for i, g := range groups {
+		if disjuncts[i], err = groupQuery(g, opts); err != nil {
+			return nil, err
+		}
+	}
+	if len(disjuncts) == 1 {
+		return disjuncts[0], nil
+	}
+	return bleve.NewDisjunctionQuery(disjuncts...), nil
+}
+
+// groupQuery returns the conjunction of the clauses in g.
+func groupQuery(g []queryToken, opts QueryOptions) (query.Query, error) {
+	var must, mustNot []query.Query
+	for _, t := range g {
+		q, err := clauseQuery(t, opts)
+		if err != nil {
+			return nil, err
+		}
+		if t.negate {
+			mustNot = append(mustNot, q)
+		} else {
+			must = append(must, q)
+		}
+	}
+	if len(mustNot) < 1 && len(must) == 1 {
+		return must[0], nil
+	}
+	b := bleve.NewBooleanQuery()
+	if len(must) < 1 {
+		// Only exclusions, match everything else.
+		b.AddMust(bleve.NewMatchAllQuery())
+	} else {
+		b.AddMust(must...)
+	}
+	b.AddMustNot(mustNot...)
+	return b, nil
+}
+
+func clauseQuery(t queryToken, opts QueryOptions) (query.Query, error) {
+	switch {
+	case t.field != "":
+		return fieldQuery(t)
+	case t.kind == queryTokenPhrase:
+		phrases := []query.Query{bleve.NewMatchPhraseQuery(t.text)}
+		for _, l := range opts.Languages {
+			if l != LanguageNone {
+				q := bleve.NewMatchPhraseQuery(t.text)
+				q.Analyzer = l.analyzer()
+				phrases = append(phrases, q)
+			}
+		}
+		if len(phrases) == 1 {
+			return phrases[0], nil
+		}
+		return bleve.NewDisjunctionQuery(phrases...), nil
+	}
+
+	term, fuzziness := t.text, opts.Fuzziness
+	if t.negate {
+		// Excluding similar words is rarely intended.
+		fuzziness = 0
+	}
+	if i := strings.LastIndexByte(term, '~'); i > 0 {
+		n, err := strconv.Atoi(term[i+1:])
+		if err != nil || n < 0 || n > MaxFuzziness {
+			return nil, &QueryError{Pos: t.pos + i, Msg: fmt.Sprintf(
+				"invalid edit distance %q, expected 0 to %d", term[i+1:], MaxFuzziness,
+			)}
+		}
+		term, fuzziness = term[:i], n
+	}
+	var alternatives []query.Query
+	for _, term := range analyzeTerm(term, opts.Languages) {
+		alternatives = append(alternatives, bleve.NewPrefixQuery(term))
+		if fuzziness > 0 && utf8.RuneCountInString(term) >= MinFuzzyTermLength {
+			fuzzy := bleve.NewFuzzyQuery(term)
+			fuzzy.SetFuzziness(fuzziness)
+			alternatives = append(alternatives, fuzzy)
+		}
+	}
+	if len(alternatives) == 1 {
+		return alternatives[0], nil
+	}
+	return bleve.NewDisjunctionQuery(alternatives...), nil
+}
+
+func fieldQuery(t queryToken) (query.Query, error) {
+	valuePos := t.pos + len(t.field) + 1
+	if t.negate {
+		valuePos++
+	}
+	if t.text == "" {
+		return nil, &QueryError{Pos: valuePos, Msg: fmt.Sprintf("missing value for %s:", t.field)}
+	}
+	switch t.field {
+	case "done":
+		v, err := strconv.ParseBool(t.text)
+		if err != nil {
+			return nil, &QueryError{Pos: valuePos, Msg: fmt.Sprintf(
+				"invalid value %q for done:, expected true or false", t.text,
+			)}
+		}
+		q := bleve.NewBoolFieldQuery(v)
+		q.SetField("done")
+		return q, nil
+
+	case "tag":
+		tag := strings.ToLower(strings.TrimLeft(t.text, "#+@"))
+		contexts := bleve.NewTermQuery(tag)
+		contexts.SetField("contexts")
+		projects := bleve.NewTermQuery(tag)
+		projects.SetField("projects")
+		return bleve.NewDisjunctionQuery(contexts, projects), nil
+
+	case "list":
+		q := bleve.NewMatchPhraseQuery(t.text)
+		q.SetField("list")
+		return q, nil
+
+	case "priority":
+		p, ok := parsePriority(t.text)
+		if !ok {
+			return nil, &QueryError{Pos: valuePos, Msg: fmt.Sprintf(
+				"invalid value %q for priority:, expected A to Z", t.text,
+			)}
+		}
+		v, inclusive := float64(p), true
+		q := bleve.NewNumericRangeInclusiveQuery(&v, &v, &inclusive, &inclusive)
+		q.SetField("priority")
+		return q, nil
+
+	case "due", "created":
+		return dateQuery(t.field, t.text, valuePos)
+	}
+	// Unreachable, lexQuery only returns queryFields.
+	panic(fmt.Errorf("unknown field %q", t.field))
+}
+
+func parsePriority(s string) (Priority, bool) {
+	if len(s) != 1 {
+		return PriorityNone, false
+	}
+	p := Priority(unicode.ToUpper(rune(s[0])))
+	return p, p != PriorityNone && p.Valid()
+}
+
+// dateQuery parses comparisons of field like "<2026-11-01" in time.Local.
+func dateQuery(field, value string, pos int) (query.Query, error) {
+	op := ""
+	for _, o := range []string{"<=", ">=", "<", ">", "="} {
+		if strings.HasPrefix(value, o) {
+			op = o
+			break
+		}
+	}
+	day, err := time.ParseInLocation(time.DateOnly, value[len(op):], time.Local)
+	if err != nil {
+		return nil, &QueryError{Pos: pos + len(op), Msg: fmt.Sprintf(
+			"invalid date %q for %s:, expected YYYY-MM-DD", value[len(op):], field,
+		)}
+	}
+	next := day.AddDate(0, 0, 1)
+	var start, end time.Time
+	switch op {
+	case "<":
+		end = day
+	case "<=":
+		end = next
+	case ">":
+		start = next
+	case ">=":
+		start = day
+	default:
+		start, end = day, next
+	}
+	// The end is exclusive and the start inclusive.
+	startInclusive, endInclusive := true, false
+	q := bleve.NewDateRangeInclusiveQuery(start, end, &startInclusive, &endInclusive)
+	q.SetField(field)
+	return q, nil
+}
+
+// lexQuery splits q into tokens.
+func lexQuery(q string) ([]queryToken, error) {
+	var tokens []queryToken
+	i := 0
+	for i < len(q) {
+		if q[i] == ' ' || q[i] == '\t' || q[i] == '\n' || q[i] == '\r' {
+			i++
+			continue
+		}
+		t := queryToken{pos: i, kind: queryTokenTerm}
+		if q[i] == '-' {
+			t.negate = true
+			i++
+			if i >= len(q) || q[i] == ' ' {
+				return nil, &QueryError{Pos: t.pos, Msg: "- without a term after it"}
+			}
+		}
+
+		// A field qualifier.
+		start := i
+		for i < len(q) && (q[i] >= 'a' && q[i] <= 'z' || q[i] >= 'A' && q[i] <= 'Z') {
+			i++
+		}
+		if i > start && i < len(q) && q[i] == ':' {
+			field := strings.ToLower(q[start:i])
+			if !slices.Contains(queryFields, field) {
+				// Titles like "Note: call mom" contain colons.
+				// The value, if any, is lexed as the next token.
+				t.text = q[start:i]
+				tokens = append(tokens, t)
+				i++
+				continue
+			}
+			t.field = field
+			i++
+		} else {
+			i = start
+		}
+
+		quoted := i < len(q) && q[i] == '"'
+		if quoted {
+			end := strings.IndexByte(q[i+1:], '"')
+			if end < 0 {
+				return nil, &QueryError{Pos: i, Msg: "unterminated quote"}
+			}
+			t.text = q[i+1 : i+1+end]
+			i += end + 2
+			if t.field == "" {
+				t.kind = queryTokenPhrase
+				if strings.TrimSpace(t.text) == "" {
+					return nil, &QueryError{Pos: t.pos, Msg: "empty phrase"}
+				}
+			}
+		} else {
+			start := i
+			for i < len(q) && q[i] != ' ' && q[i] != '\t' && q[i] != '\n' && q[i] != '\r' {
+				if q[i] == '"' {
+					return nil, &QueryError{Pos: i, Msg: "unexpected quote within a term"}
+				}
+				i++
+			}
+			t.text = q[start:i]
+		}
+
+		if t.field == "" && !t.negate && !quoted {
+			switch t.text {
+			case "AND":
+				t.kind = queryTokenAnd
+			case "OR":
+				t.kind = queryTokenOr
+			}
+		}
+		tokens = append(tokens, t)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,104 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+func TestFindQuery(t *testing.T) {
+	r := newRepository(t)
+	titles := map[string]string{}
+	for _, title := range []string{
+		"Feed cat dog", "Feed cat", "Walk dog", "Buy bird seed",
+		"Note: call mom", "Vote OR abstain",
+	} {
+		id, err := r.Add(title, false, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles[id] = title
+	}
+
+	for _, tt := range []struct {
+		query  string
+		expect []string
+	}{
+		{"cat dog", []string{"Feed cat dog"}},
+		{"cat AND dog", []string{"Feed cat dog"}},
+		{"cat OR bird", []string{"Feed cat dog", "Feed cat", "Buy bird seed"}},
+		// AND binds tighter than OR.
+		{"cat dog OR bird", []string{"Feed cat dog", "Buy bird seed"}},
+		{"bird OR cat dog", []string{"Feed cat dog", "Buy bird seed"}},
+		{"walk OR cat -dog", []string{"Walk dog", "Feed cat"}},
+		{"-dog -cat", []string{"Buy bird seed", "Note: call mom", "Vote OR abstain"}},
+		{`"feed cat"`, []string{"Feed cat dog", "Feed cat"}},
+		{`"cat feed"`, nil},
+		// Quoted operators are phrases, which only consist of stop words.
+		{`cat "OR" bird`, nil},
+		{`"AND" OR abstain`, []string{"Vote OR abstain"}},
+		// Unknown qualifiers are terms.
+		{"note: call", []string{"Note: call mom"}},
+		{"note:call", []string{"Note: call mom"}},
+		{"-note:", []string{
+			"Feed cat dog", "Feed cat", "Walk dog", "Buy bird seed", "Vote OR abstain",
+		}},
+	} {
+		found, err := r.Find(context.Background(), tt.query)
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		var got []string
+		for _, td := range found {
+			got = append(got, titles[td.ID])
+		}
+		slices.Sort(got)
+		slices.Sort(tt.expect)
+		if !slices.Equal(got, tt.expect) {
+			t.Errorf("%s: %q, want %q", tt.query, got, tt.expect)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, tt := range []struct {
+		query string
+		pos   int
+		msg   string
+	}{
+		{"", 0, "empty query"},
+		{"   ", 0, "empty query"},
+		{"AND cat", 0, "AND without a term before it"},
+		{"cat OR OR dog", 7, "OR without a term before it"},
+		{"cat OR", 4, "OR without a term after it"},
+		{"cat AND", 4, "AND without a term after it"},
+		{"cat -", 4, "- without a term after it"},
+		{"- cat", 0, "- without a term after it"},
+		{`cat "feed`, 4, "unterminated quote"},
+		{`"  "`, 0, "empty phrase"},
+		{`ca"t`, 2, "unexpected quote within a term"},
+		{"cat~3", 3, `invalid edit distance "3", expected 0 to 2`},
+		{"cat~x", 3, `invalid edit distance "x", expected 0 to 2`},
+		{"done:", 5, "missing value for done:"},
+		{"-tag:", 5, "missing value for tag:"},
+		{"done:maybe", 5, `invalid value "maybe" for done:, expected true or false`},
+		{"priority:AA", 9, `invalid value "AA" for priority:, expected A to Z`},
+		{"due:<2026-13-01", 5, `invalid date "2026-13-01" for due:, expected YYYY-MM-DD`},
+		{"created:soon", 8, `invalid date "soon" for created:, expected YYYY-MM-DD`},
+	} {
+		_, err := repository.ParseQuery(tt.query, repository.QueryOptions{})
+		var qe *repository.QueryError
+		if !errors.As(err, &qe) {
+			t.Errorf("%q: expected *QueryError, got %v", tt.query, err)
+			continue
+		}
+		if qe.Pos != tt.pos || qe.Msg != tt.msg {
+			t.Errorf("%q: error at %d %q, want at %d %q", tt.query, qe.Pos, qe.Msg, tt.pos, tt.msg)
+		}
+	}
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the FREQ of a recurrence rule.
+type Frequency string
+
+const (
+	FreqDaily   Frequency = "DAILY"
+	FreqWeekly  Frequency = "WEEKLY"
+	FreqMonthly Frequency = "MONTHLY"
+	FreqYearly  Frequency = "YEARLY"
+)
+
+// Recurrence is a parsed recurrence rule.
+// Supported is the following subset of the RFC 5545 RRULE:
+//
+//	FREQ=DAILY|WEEKLY|MONTHLY|YEARLY (required)
+//	INTERVAL=n
+//	BYDAY=MO,TU,... (WEEKLY only)
+//	BYMONTHDAY=n (MONTHLY only, -1 for the last day of the month)
+//	COUNT=n
+//	UNTIL=yyyymmdd or yyyymmddThhmmssZ
+type Recurrence struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay int
+	Count      int
+	Until      time.Time
+}
+
+var weekdays = [...]string{"SU", "MO", "TU", "WE", "TH", "FR", "SA"}
+
+// ParseRecurrence parses an RRULE value with or without the "RRULE:" prefix.
+func ParseRecurrence(rule string) (r Recurrence, err error) {
+	rule = strings.TrimPrefix(strings.TrimSpace(rule), "RRULE:")
+	r.Interval = 1
+	for _, part := range strings.Split(rule, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return Recurrence{}, fmt.Errorf("malformed rule part: %q", part)
+		}
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = Frequency(strings.ToUpper(value))
+			switch r.Freq {
+			case FreqDaily, FreqWeekly, FreqMonthly, FreqYearly:
+			default:
+				return Recurrence{}, fmt.Errorf("unsupported FREQ: %q", value)
+			}
+		case "INTERVAL":
+			if r.Interval, err = strconv.Atoi(value); err != nil || r.Interval < 1 {
+				return Recurrence{}, fmt.Errorf("invalid INTERVAL: %q", value)
+			}
+		case "BYDAY":
+			for _, d := range strings.Split(strings.ToUpper(value), ",") {
+				i := slices.Index(weekdays[:], d)
+				if i < 0 {
+					return Recurrence{}, fmt.Errorf("unsupported BYDAY: %q", d)
+				}
+				r.ByDay = append(r.ByDay, time.Weekday(i))
+			}
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = strconv.Atoi(value)
+			if err != nil || r.ByMonthDay == 0 || r.ByMonthDay < -1 || r.ByMonthDay > 31 {
+				return Recurrence{}, fmt.Errorf("unsupported BYMONTHDAY: %q", value)
+			}
+		case "COUNT":
+			if r.Count, err = strconv.Atoi(value); err != nil || r.Count < 1 {
+				return Recurrence{}, fmt.Errorf("invalid COUNT: %q", value)
+			}
+		case "UNTIL":
+			if r.Until, err = time.Parse("20060102T150405Z", value); err != nil {
+				if r.Until, err = time.Parse("20060102", value); err != nil {
+					return Recurrence{}, fmt.Errorf("invalid UNTIL: %q", value)
+				}
+				// A date includes the whole day.
+				r.Until = r.Until.Add(24*time.Hour - time.Second)
+			}
+		default:
+			return Recurrence{}, fmt.Errorf("unsupported rule part: %q", name)
+		}
+	}
+	switch {
+	case r.Freq == "":
+		return Recurrence{}, fmt.Errorf("missing FREQ")
+	case len(r.ByDay) > 0 && r.Freq != FreqWeekly:
+		return Recurrence{}, fmt.Errorf("BYDAY is only supported with FREQ=WEEKLY")
+	case r.ByMonthDay != 0 && r.Freq != FreqMonthly:
+		return Recurrence{}, fmt.Errorf("BYMONTHDAY is only supported with FREQ=MONTHLY")
+	case r.Count != 0 && !r.Until.IsZero():
+		return Recurrence{}, fmt.Errorf("COUNT and UNTIL are mutually exclusive")
+	}
+	return r, nil
+}
+
+// String returns the RRULE value of r without the "RRULE:" prefix.
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		d := make([]string, len(r.ByDay))
+		for i := range r.ByDay {
+			d[i] = weekdays[r.ByDay[i]]
+		}
+		parts = append(parts, "BYDAY="+strings.Join(d, ","))
+	}
+	if r.ByMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.ByMonthDay))
+	}
+	if r.Count != 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return strings.Join(parts, ";")
+}
+
+// maxIterations limits the search for the next occurrence
+// (e.g. BYMONTHDAY=31 skips months with fewer days).
+const maxIterations = 1000
+
+// Next returns the first occurrence after prev computed in loc.
+// The wall clock time of prev is preserved across DST transitions.
+// ok is false if the rule has no further occurrences. COUNT is not
+// taken into account, the caller is expected to use Remaining instead.
+func (r Recurrence) Next(prev time.Time, loc *time.Location) (next time.Time, ok bool) {
+	prev = prev.In(loc)
+	y, m, d := prev.Date()
+	hh, mm, ss := prev.Clock()
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hh, mm, ss, 0, loc)
+	}
+
+	switch r.Freq {
+	case FreqDaily:
+		next = at(y, m, d+r.Interval)
+	case FreqWeekly:
+		if len(r.ByDay) < 1 {
+			next = at(y, m, d+7*r.Interval)
+			break
+		}
+		// Weeks start on Monday (WKST=MO).
+		weekStart := at(y, m, d-(int(prev.Weekday())+6)%7)
+		for i := 1; i <= 7*r.Interval+7; i++ {
+			c := at(y, m, d+i)
+			week := daysBetween(weekStart, c) / 7
+			if week%r.Interval == 0 && slices.Contains(r.ByDay, c.Weekday()) {
+				next = c
+				break
+			}
+		}
+	case FreqMonthly:
+		day := r.ByMonthDay
+		if day == 0 {
+			day = d
+		}
+		for i := 0; i < maxIterations && next.IsZero(); i += r.Interval {
+			// Months without the given day are skipped.
+			first := at(y, m+time.Month(i), 1)
+			c, ok := monthDay(first, day)
+			if ok && c.After(prev) {
+				next = c
+			}
+		}
+	case FreqYearly:
+		for i := r.Interval; i < maxIterations && next.IsZero(); i += r.Interval {
+			// February 29 only occurs in leap years.
+			if c := at(y+i, m, d); c.Day() == d {
+				next = c
+			}
+		}
+	}
+	if next.IsZero() || (!r.Until.IsZero() && next.After(r.Until)) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// Remaining returns the rule for the occurrences following the next one.
+// ok is false if COUNT is exhausted.
+func (r Recurrence) Remaining() (rest Recurrence, ok bool) {
+	switch r.Count {
+	case 0:
+		return r, true
+	case 1:
+		return Recurrence{}, false
+	}
+	r.Count--
+	return r, true
+}
+
+// monthDay returns the given day (-1 for the last day)
+// of the month of first. ok is false if the month has fewer days.
+func monthDay(first time.Time, day int) (t time.Time, ok bool) {
+	if day == -1 {
+		return first.AddDate(0, 1, -1), true
+	}
+	t = first.AddDate(0, 0, day-1)
+	return t, t.Month() == first.Month()
+}
+
+// daysBetween returns the number of calendar days from a to b.
+func daysBetween(a, b time.Time) int {
+	ua := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	ub := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(ub.Sub(ua).Hours() / 24)
+}
@@ -0,0 +1,210 @@
+package repository_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+func TestRecurrenceNext(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	date := func(y int, m time.Month, d, hh, mm int) time.Time {
+		return time.Date(y, m, d, hh, mm, 0, 0, berlin)
+	}
+
+	for _, tt := range []struct {
+		name string
+		rule string
+		prev time.Time
+		// expect are the following occurrences, Next is called with
+		// the previous one. The rule has no more occurrences after them
+		// if exhausted is true.
+		expect    []time.Time
+		exhausted bool
+	}{
+		{
+			name: "daily into summer time",
+			rule: "FREQ=DAILY",
+			prev: date(2026, 3, 28, 9, 0),
+			expect: []time.Time{
+				date(2026, 3, 29, 9, 0), date(2026, 3, 30, 9, 0),
+			},
+		},
+		{
+			name: "daily into winter time",
+			rule: "FREQ=DAILY;INTERVAL=2",
+			prev: date(2026, 10, 24, 23, 30),
+			expect: []time.Time{
+				date(2026, 10, 26, 23, 30), date(2026, 10, 28, 23, 30),
+			},
+		},
+		{
+			name:   "weekly into winter time",
+			rule:   "FREQ=WEEKLY",
+			prev:   date(2026, 10, 20, 8, 15),
+			expect: []time.Time{date(2026, 10, 27, 8, 15), date(2026, 11, 3, 8, 15)},
+		},
+		{
+			name: "weekly interval by day",
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR",
+			// Wednesday.
+			prev: date(2026, 10, 21, 9, 0),
+			expect: []time.Time{
+				date(2026, 10, 23, 9, 0), // Friday of the same week.
+				date(2026, 11, 2, 9, 0),  // The week in between is skipped.
+				date(2026, 11, 6, 9, 0),
+				date(2026, 11, 16, 9, 0),
+			},
+		},
+		{
+			name: "weekly by day starting on Sunday",
+			rule: "FREQ=WEEKLY;INTERVAL=2;BYDAY=SU,MO",
+			// Sunday ends the week.
+			prev: date(2026, 10, 25, 9, 0),
+			expect: []time.Time{
+				date(2026, 11, 2, 9, 0), date(2026, 11, 8, 9, 0), date(2026, 11, 16, 9, 0),
+			},
+		},
+		{
+			name: "monthly on the 31st skips short months",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=31",
+			prev: date(2026, 1, 31, 9, 0),
+			expect: []time.Time{
+				date(2026, 3, 31, 9, 0), date(2026, 5, 31, 9, 0),
+				date(2026, 7, 31, 9, 0), date(2026, 8, 31, 9, 0),
+			},
+		},
+		{
+			name: "monthly on the last day",
+			rule: "FREQ=MONTHLY;BYMONTHDAY=-1",
+			prev: date(2026, 1, 31, 9, 0),
+			expect: []time.Time{
+				date(2026, 2, 28, 9, 0), date(2026, 3, 31, 9, 0), date(2026, 4, 30, 9, 0),
+			},
+		},
+		{
+			name:   "monthly on the last day in a leap year",
+			rule:   "FREQ=MONTHLY;BYMONTHDAY=-1",
+			prev:   date(2028, 1, 31, 9, 0),
+			expect: []time.Time{date(2028, 2, 29, 9, 0)},
+		},
+		{
+			name:   "monthly into summer time",
+			rule:   "FREQ=MONTHLY",
+			prev:   date(2026, 3, 15, 18, 0),
+			expect: []time.Time{date(2026, 4, 15, 18, 0)},
+		},
+		{
+			name:   "yearly on February 29",
+			rule:   "FREQ=YEARLY",
+			prev:   date(2024, 2, 29, 9, 0),
+			expect: []time.Time{date(2028, 2, 29, 9, 0)},
+		},
+		{
+			name: "until a date",
+			rule: "FREQ=DAILY;UNTIL=20261021",
+			prev: date(2026, 10, 19, 23, 0),
+			// The date is in UTC and includes the whole day,
+			// which ends at 01:59:59 in Berlin.
+			expect:    []time.Time{date(2026, 10, 20, 23, 0), date(2026, 10, 21, 23, 0)},
+			exhausted: true,
+		},
+		{
+			name:      "until a time",
+			rule:      "FREQ=WEEKLY;UNTIL=20261103T070000Z",
+			prev:      date(2026, 10, 20, 8, 0),
+			expect:    []time.Time{date(2026, 10, 27, 8, 0), date(2026, 11, 3, 8, 0)},
+			exhausted: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := repository.ParseRecurrence(tt.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			prev := tt.prev
+			for i, expect := range tt.expect {
+				next, ok := r.Next(prev, berlin)
+				if !ok || !next.Equal(expect) {
+					t.Fatalf("occurrence %d: %v %t, want %v", i, next, ok, expect)
+				}
+				prev = next
+			}
+			if next, ok := r.Next(prev, berlin); ok == tt.exhausted {
+				t.Fatalf("after %v: %v %t, want %t", prev, next, ok, !tt.exhausted)
+			}
+		})
+	}
+}
+
+func TestRecurrenceRemaining(t *testing.T) {
+	r, err := repository.ParseRecurrence("RRULE:FREQ=DAILY;COUNT=3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The todo with COUNT=3 is the first of 3 occurrences.
+	var rules []string
+	for {
+		rest, ok := r.Remaining()
+		if !ok {
+			break
+		}
+		rules = append(rules, rest.String())
+		r = rest
+	}
+	if len(rules) != 2 || rules[0] != "FREQ=DAILY;COUNT=2" || rules[1] != "FREQ=DAILY;COUNT=1" {
+		t.Fatalf("remaining rules %q", rules)
+	}
+
+	r, err = repository.ParseRecurrence("FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rest, ok := r.Remaining(); !ok || rest.String() != r.String() {
+		t.Fatalf("remaining without COUNT: %v %t", rest, ok)
+	}
+}
+
+func TestRecurrenceCompleted(t *testing.T) {
+	r := newRepository(t)
+	due := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	id, err := r.Add("Water plants", false, due)
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo, err := r.Get(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo.Recurrence, todo.Due, todo.TimeZone = "FREQ=DAILY;COUNT=2", due, "UTC"
+	todo.Remind = due.Add(-time.Hour)
+	if err := r.Replace(todo); err != nil {
+		t.Fatal(err)
+	}
+
+	// Completing the first occurrence adds the last one.
+	if _, err := r.Toggle(id); err != nil {
+		t.Fatal(err)
+	}
+	all := snapshot(t, r)
+	if len(all) != 2 {
+		t.Fatalf("%d todos, want 2", len(all))
+	}
+	next := all[0]
+	if next.Done || !next.Due.Equal(due.AddDate(0, 0, 1)) ||
+		!next.Remind.Equal(next.Due.Add(-time.Hour)) || next.Recurrence != "FREQ=DAILY;COUNT=1" {
+		t.Fatalf("next occurrence %#v", next)
+	}
+
+	// Completing the last occurrence adds none.
+	if _, err := r.Toggle(next.ID); err != nil {
+		t.Fatal(err)
+	}
+	if n := r.Len(); n != 2 {
+		t.Fatalf("%d todos, want 2", n)
+	}
+}
@@ -1,34 +1,126 @@
 package repository
 
 import (
+	"context"
 	"fmt"
+	"maps"
 	"slices"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/blevesearch/bleve/v2"
 )
 
+// DefaultList is the name of the list new todos are added to by default.
+const DefaultList = "todos"
+
 type Todo struct {
-	ID      string
-	Title   string
-	Done    bool
-	Created time.Time
+	ID        string    `json:"id"`
+	List      string    `json:"list"`
+	Title     string    `json:"title"`
+	Done      bool      `json:"done"`
+	Created   time.Time `json:"created"`
+	Completed time.Time `json:"completed"`
+	Due       time.Time `json:"due"`
+	Remind    time.Time `json:"remind"`
+	Priority  Priority  `json:"priority"`
+	// Archived todos are only listed by Archived and FindArchived.
+	Archived bool `json:"archived"`
+	// Position is the fractional index key todos are ordered by ascending.
+	Position string `json:"position"`
+	// Version is incremented on every change of the todo
+	// and allows rejecting changes based on an outdated state.
+	Version uint64 `json:"version"`
+
+	// Recurrence is the RRULE (see ParseRecurrence) the todo repeats by.
+	Recurrence string `json:"recurrence,omitempty"`
+	// TimeZone is the IANA time zone name recurrences are computed in.
+	// time.Local is used if empty.
+	TimeZone string `json:"timeZone,omitempty"`
+	// Parent is the ID of the todo this todo is a subtask of.
+	Parent string `json:"parent,omitempty"`
+	// UID is the iCalendar UID of todos created through CalDAV or imports.
+	UID string `json:"uid,omitempty"`
+	// Projects are todo.txt "+project" tags.
+	Projects []string `json:"projects,omitempty"`
+	// Contexts are todo.txt "@context" tags.
+	Contexts []string `json:"contexts,omitempty"`
+	// Meta holds any additional todo.txt "key:value" pairs.
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+func (t Todo) validate() error {
+	if !t.Priority.Valid() {
+		return fmt.Errorf("invalid priority: %q", t.Priority)
+	}
+	if t.Recurrence != "" {
+		if _, err := ParseRecurrence(t.Recurrence); err != nil {
+			return fmt.Errorf("invalid recurrence: %w", err)
+		}
+	}
+	if t.TimeZone != "" {
+		if _, err := time.LoadLocation(t.TimeZone); err != nil {
+			return fmt.Errorf("invalid time zone: %w", err)
+		}
+	}
+	return nil
+}
+
+// Priority ranges from 'A' (highest) to 'Z' (lowest).
+// The zero value PriorityNone means no priority.
+type Priority byte
+
+const PriorityNone Priority = 0
+
+// Valid returns true if p is either PriorityNone or in range 'A' to 'Z'.
+func (p Priority) Valid() bool { return p == PriorityNone || (p >= 'A' && p <= 'Z') }
+
+func (p Priority) String() string {
+	if p == PriorityNone {
+		return ""
+	}
+	return string(rune(p))
 }
 
 type Repository struct {
-	lock      sync.Mutex
+	// dir is the data directory, empty for in-memory repositories.
+	dir string
+	// lock is held for reading by methods that don't modify the repository,
+	// which can run in parallel.
+	lock      sync.RWMutex
 	idCounter uint64
 	index     bleve.Index
 	todos     []Todo
+	// byID is the index of each todo in todos by ID.
+	byID map[string]int
+	// byUID is the index of each todo with a UID in todos by UID.
+	byUID     map[string]int
+	listeners listeners
+	// fuzziness is the default edit distance of search terms.
+	fuzziness int
+	languages languageSettings
+	boosts    Boosts
+	// history are the undoable changes, oldest first.
+	history []historyEntry
+	// first is the smallest position if firstValid (see firstPosition).
+	first      string
+	firstValid bool
+	// order are the results of sorted by archive state, nil until computed.
+	// Added todos are inserted, other changes of positions clear it.
+	orderLock sync.Mutex
+	order     [2][]int
+	// journal reverts the changes of todos made by the running Update
+	// in reverse order (see rollback), nil outside of Update.
+	journal []func()
 }
 
-// NewRepository creates a new repository instance.
-// Use path="" for in-memory search index.
+// NewRepository creates a new in-memory repository instance.
+// Use Open for a repository persisted on disk.
 func NewRepository() (*Repository, error) {
 	// Create a new in-memory bleve search index.
-	indexMapping := bleve.NewIndexMapping()
+	indexMapping := newIndexMapping()
 
 	index, err := bleve.NewUsing("",
 		indexMapping,
@@ -38,102 +130,1221 @@ func NewRepository() (*Repository, error) {
 	if err != nil {
 		return nil, fmt.Errorf("creating new bleve index: %w", err)
 	}
-	return &Repository{index: index}, nil
+	return &Repository{
+		index:      index,
+		byID:       map[string]int{},
+		byUID:      map[string]int{},
+		fuzziness:  DefaultFuzziness,
+		firstValid: true,
+	}, nil
 }
 
+// findByID returns the index of the todo in s.todos, -1 if id isn't found.
+// Must be called with s.lock held.
 func (s *Repository) findByID(id string) (index int) {
-	for i := range s.todos {
-		if s.todos[i].ID == id {
-			return i
-		}
+	if i, ok := s.byID[id]; ok {
+		return i
 	}
 	return -1
 }
 
+// indexByID returns the index of each todo by ID.
+func indexByID(todos []Todo) map[string]int {
+	m := make(map[string]int, len(todos))
+	for i := range todos {
+		m[todos[i].ID] = i
+	}
+	return m
+}
+
+// indexByUID returns the index of each todo with a UID by UID.
+func indexByUID(todos []Todo) map[string]int {
+	m := map[string]int{}
+	for i := range todos {
+		if todos[i].UID != "" {
+			m[todos[i].UID] = i
+		}
+	}
+	return m
+}
+
+// setTodo replaces the todo at index i by t.
+// Must be called with s.lock held for writing.
+func (s *Repository) setTodo(i int, t Todo) {
+	if prev := s.todos[i].UID; prev != t.UID {
+		if j, ok := s.byUID[prev]; ok && j == i {
+			delete(s.byUID, prev)
+		}
+		if t.UID != "" {
+			s.byUID[t.UID] = i
+		}
+	}
+	if s.journal != nil {
+		prev := s.todos[i]
+		s.journal = append(s.journal, func() { s.todos[i] = prev })
+	}
+	p := s.todos[i].Position
+	if t.Position < s.first {
+		s.first = t.Position
+	} else if p == s.first && t.Position != p {
+		s.firstValid = false
+	}
+	if t.Position != p || t.Archived != s.todos[i].Archived {
+		s.order = [2][]int{}
+	}
+	s.todos[i] = t
+}
+
+// appendTodos appends todos to s.todos.
+// Must be called with s.lock held for writing.
+func (s *Repository) appendTodos(todos ...Todo) {
+	if s.journal != nil {
+		n := len(s.todos)
+		s.journal = append(s.journal, func() {
+			clear(s.todos[n:])
+			s.todos = s.todos[:n]
+		})
+	}
+	if len(todos) > 1 {
+		// Sorting once is cheaper than inserting each.
+		s.order = [2][]int{}
+	}
+	for _, t := range todos {
+		s.byID[t.ID] = len(s.todos)
+		if t.UID != "" {
+			s.byUID[t.UID] = len(s.todos)
+		}
+		if s.first == "" || t.Position < s.first {
+			s.first = t.Position
+		}
+		if o := s.order[archivedKey(t.Archived)]; o != nil {
+			// Before todos with an equal position, which are older.
+			j, _ := slices.BinarySearchFunc(o, t.Position, func(i int, pos string) int {
+				return strings.Compare(s.todos[i].Position, pos)
+			})
+			s.order[archivedKey(t.Archived)] = slices.Insert(o, j, len(s.todos))
+		}
+		s.todos = append(s.todos, t)
+	}
+}
+
+// removeTodos removes the todos at indexes from s.todos
+// keeping the order of the others.
+// Must be called with s.lock held for writing.
+func (s *Repository) removeTodos(indexes ...int) {
+	if s.journal != nil {
+		prev := slices.Clone(s.todos)
+		s.journal = append(s.journal, func() { s.todos = prev })
+	}
+	removed := make(map[int]bool, len(indexes))
+	for _, i := range indexes {
+		removed[i] = true
+		if s.todos[i].Position == s.first {
+			s.firstValid = false
+		}
+		delete(s.byID, s.todos[i].ID)
+		delete(s.byUID, s.todos[i].UID)
+	}
+	n := 0
+	for i, t := range s.todos {
+		if removed[i] {
+			continue
+		}
+		s.todos[n] = t
+		s.byID[t.ID] = n
+		if t.UID != "" {
+			s.byUID[t.UID] = n
+		}
+		n++
+	}
+	clear(s.todos[n:])
+	s.todos = s.todos[:n]
+	s.order = [2][]int{}
+}
+
 func (s *Repository) Close() error { return s.index.Close() }
 
 // Len returns the number of todo items stored.
-func (s *Repository) Len() int { return len(s.todos) }
+func (s *Repository) Len() (n int) {
+	_ = s.View(func(tx *Tx) error { n = tx.Len(); return nil })
+	return n
+}
+
+// Len is Repository.Len within the transaction.
+func (tx *Tx) Len() int { return len(tx.s.todos) }
 
 // Add adds a new todo item.
 func (s *Repository) Add(title string, done bool, now time.Time) (id string, err error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+	err = s.Update(func(tx *Tx) error {
+		id, err = tx.Add(title, done, now)
+		return err
+	})
+	return id, err
+}
+
+// Add is Repository.Add within the transaction.
+func (tx *Tx) Add(title string, done bool, now time.Time) (id string, err error) {
+	if !tx.writable {
+		return "", ErrReadOnly
+	}
+	s := tx.s
 
 	s.idCounter++
 	id = strconv.FormatInt(int64(s.idCounter), 16)
 
-	t := Todo{ID: id, Title: title, Done: done, Created: now}
-	if err := s.index.Index(id, t); err != nil {
+	pos, err := s.positionFirst()
+	if err != nil {
 		return "", err
 	}
-	s.todos = append(s.todos, t)
+	t := Todo{
+		ID: id, List: DefaultList, Title: title, Done: done, Created: now, Position: pos,
+		Version: 1,
+	}
+	if done {
+		t.Completed = now
+	}
+	if err := tx.index(t); err != nil {
+		return "", err
+	}
+	s.appendTodos(t)
+	tx.record(Event{Type: EventCreated, Todo: t, Time: now})
 	return id, nil
 }
 
-var ErrNotFound = fmt.Errorf("not found")
+// Import adds all todos in a single index batch and returns their new IDs.
+// IDs and positions of todos are replaced, each todo is positioned
+// before the previous one. A zero Created time is replaced with now
+// and todos without a list are added to DefaultList.
+// A Parent referring to the ID of another todo in todos
+// is replaced with the parent's new ID.
+func (s *Repository) Import(todos []Todo, now time.Time) (ids []string, err error) {
+	err = s.Update(func(tx *Tx) error {
+		ids, err = tx.Import(todos, now)
+		return err
+	})
+	return ids, err
+}
+
+// Import is Repository.Import within the transaction.
+func (tx *Tx) Import(todos []Todo, now time.Time) (ids []string, err error) {
+	if !tx.writable {
+		return nil, ErrReadOnly
+	}
+	s := tx.s
+
+	first := s.firstPosition()
+	ids = make([]string, len(todos))
+	added := make([]Todo, len(todos))
+	newIDs := make(map[string]string, len(todos))
+	for i, t := range todos {
+		ids[i] = strconv.FormatInt(int64(s.idCounter)+int64(i)+1, 16)
+		if t.ID == "" {
+			continue
+		}
+		if _, ok := newIDs[t.ID]; ok {
+			return nil, fmt.Errorf("todo %d: duplicate ID: %q", i, t.ID)
+		}
+		newIDs[t.ID] = ids[i]
+	}
+	for i, t := range todos {
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("todo %d: %w", i, err)
+		}
+		t.ID = ids[i]
+		t.Version = 1
+		if first, err = positionBetween("", first); err != nil {
+			return nil, err
+		}
+		t.Position = first
+		if id, ok := newIDs[t.Parent]; ok {
+			t.Parent = id
+		}
+		if t.Created.IsZero() {
+			t.Created = now
+		}
+		if t.List == "" {
+			t.List = DefaultList
+		}
+		if err := tx.index(t); err != nil {
+			return nil, err
+		}
+		added[i] = t
+	}
+	s.idCounter += uint64(len(todos))
+	s.appendTodos(added...)
+	for _, t := range added {
+		tx.record(Event{Type: EventCreated, Todo: t, Time: now})
+	}
+	return ids, nil
+}
+
+var (
+	ErrNotFound = fmt.Errorf("not found")
+	// ErrVersionConflict is returned when a todo was changed
+	// since the version a change is based on.
+	ErrVersionConflict = fmt.Errorf("version conflict")
+)
 
 // Toggle toggles the "done" field of the given todo.
+// Completing a recurring todo adds its next occurrence as a new todo
+// and moves the recurrence rule over to it.
 // Returns ErrNotFound if id isn't found.
 func (s *Repository) Toggle(id string) (newState Todo, err error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+	err = s.Update(func(tx *Tx) error {
+		newState, err = tx.Toggle(id)
+		return err
+	})
+	return newState, err
+}
+
+// Toggle is Repository.Toggle within the transaction.
+func (tx *Tx) Toggle(id string) (newState Todo, err error) {
+	if !tx.writable {
+		return Todo{}, ErrReadOnly
+	}
+	s := tx.s
 
 	i := s.findByID(id)
 	if i < 0 {
 		return Todo{}, ErrNotFound
 	}
-	s.todos[i].Done = !s.todos[i].Done
+	if err := tx.setDone([]int{i}, !s.todos[i].Done, time.Now()); err != nil {
+		return Todo{}, err
+	}
 	return s.todos[i], nil
 }
 
-// Remove removes a todo item. No-op if id doesn't exist.
-func (s *Repository) Remove(id string) error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// SetDone sets the "done" field of all given todos in a single index batch.
+// Like Toggle, completing a recurring todo adds its next occurrence.
+// Unknown IDs and todos that already are in the given state are skipped.
+// Returns the number of todos changed.
+func (s *Repository) SetDone(ids []string, done bool) (changed int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		changed, err = tx.SetDone(ids, done)
+		return err
+	})
+	return changed, err
+}
+
+// SetDone is Repository.SetDone within the transaction.
+func (tx *Tx) SetDone(ids []string, done bool) (changed int, err error) {
+	if !tx.writable {
+		return 0, ErrReadOnly
+	}
+	s := tx.s
+
+	var indexes []int
+	for _, i := range s.findAll(ids) {
+		if s.todos[i].Done != done {
+			indexes = append(indexes, i)
+		}
+	}
+	if err := tx.setDone(indexes, done, time.Now()); err != nil {
+		return 0, err
+	}
+	return len(indexes), nil
+}
+
+// SetDoneIfVersion sets the "done" field of the given todo
+// unless it was changed since version.
+// Like Toggle, completing a recurring todo adds its next occurrence.
+// No-op if the todo already is in the given state.
+// Returns ErrNotFound if id isn't found and ErrVersionConflict
+// along with the current state if the todo has another version.
+func (s *Repository) SetDoneIfVersion(
+	id string, done bool, version uint64,
+) (t Todo, err error) {
+	err = s.Update(func(tx *Tx) error {
+		t, err = tx.SetDoneIfVersion(id, done, version)
+		return err
+	})
+	return t, err
+}
+
+// SetDoneIfVersion is Repository.SetDoneIfVersion within the transaction.
+func (tx *Tx) SetDoneIfVersion(id string, done bool, version uint64) (Todo, error) {
+	if !tx.writable {
+		return Todo{}, ErrReadOnly
+	}
+	s := tx.s
 
 	i := s.findByID(id)
 	if i < 0 {
+		return Todo{}, ErrNotFound
+	}
+	if s.todos[i].Version != version {
+		return s.todos[i], ErrVersionConflict
+	}
+	if s.todos[i].Done != done {
+		if err := tx.setDone([]int{i}, done, time.Now()); err != nil {
+			return Todo{}, err
+		}
+	}
+	return s.todos[i], nil
+}
+
+// setDone sets the "done" field of the todos at the given indexes
+// and adds the next occurrences of completed recurring todos.
+func (tx *Tx) setDone(indexes []int, done bool, now time.Time) error {
+	if len(indexes) < 1 {
 		return nil
 	}
+	s := tx.s
+	changed := make([]Todo, len(indexes))
+	var added []Todo
+	var events, created []Event
+	first := s.firstPosition()
+	for j, i := range indexes {
+		t := s.todos[i]
+		t.Done = done
+		t.Version++
+		if t.Done {
+			t.Completed = now
+		} else {
+			t.Completed = time.Time{}
+		}
+
+		if t.Done && t.Recurrence != "" {
+			next, ok, err := nextOccurrence(t, now)
+			if err != nil {
+				return fmt.Errorf("computing next occurrence: %w", err)
+			}
+			if ok {
+				next.ID = strconv.FormatInt(int64(s.idCounter)+int64(len(added))+1, 16)
+				next.Version = 1
+				if first, err = positionBetween("", first); err != nil {
+					return err
+				}
+				next.Position = first
+				if err := tx.index(next); err != nil {
+					return err
+				}
+				added = append(added, next)
+				created = append(created, Event{Type: EventCreated, Todo: next, Time: now})
+			}
+			t.Recurrence = ""
+		}
+		if err := tx.index(t); err != nil {
+			return err
+		}
+		changed[j] = t
+		events = append(events, Event{
+			Type: EventToggled, Todo: t, Previous: s.todos[i], Time: now,
+		})
+	}
+	for j, i := range indexes {
+		s.setTodo(i, changed[j])
+	}
+	s.idCounter += uint64(len(added))
+	s.appendTodos(added...)
+	tx.record(events...)
+	tx.record(created...)
+	return nil
+}
+
+// AddTag adds the context tag to all given todos in a single index batch.
+// Unknown IDs and todos that already have the tag are skipped.
+// Returns the number of todos changed.
+func (s *Repository) AddTag(ids []string, tag string) (changed int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		changed, err = tx.AddTag(ids, tag)
+		return err
+	})
+	return changed, err
+}
+
+// AddTag is Repository.AddTag within the transaction.
+func (tx *Tx) AddTag(ids []string, tag string) (changed int, err error) {
+	return tx.updateAll(ids, func(t *Todo) bool {
+		if slices.Contains(t.Contexts, tag) {
+			return false
+		}
+		t.Contexts = append(slices.Clone(t.Contexts), tag)
+		return true
+	})
+}
+
+// Archive archives all given todos in a single index batch.
+// Unknown IDs and archived todos are skipped.
+// Returns the number of todos archived.
+func (s *Repository) Archive(ids []string) (changed int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		changed, err = tx.Archive(ids)
+		return err
+	})
+	return changed, err
+}
+
+// Archive is Repository.Archive within the transaction.
+func (tx *Tx) Archive(ids []string) (changed int, err error) {
+	return tx.updateAll(ids, func(t *Todo) bool {
+		if t.Archived {
+			return false
+		}
+		t.Archived = true
+		return true
+	})
+}
+
+// Unarchive restores all given archived todos in a single index batch.
+// Unknown IDs and todos that aren't archived are skipped.
+// Returns the number of todos restored.
+func (s *Repository) Unarchive(ids []string) (changed int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		changed, err = tx.Unarchive(ids)
+		return err
+	})
+	return changed, err
+}
+
+// Unarchive is Repository.Unarchive within the transaction.
+func (tx *Tx) Unarchive(ids []string) (changed int, err error) {
+	return tx.updateAll(ids, func(t *Todo) bool {
+		if !t.Archived {
+			return false
+		}
+		t.Archived = false
+		return true
+	})
+}
+
+// SetList moves all given todos to list in a single index batch.
+// Unknown IDs are skipped. Returns the number of todos changed.
+func (s *Repository) SetList(ids []string, list string) (changed int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		changed, err = tx.SetList(ids, list)
+		return err
+	})
+	return changed, err
+}
+
+// SetList is Repository.SetList within the transaction.
+func (tx *Tx) SetList(ids []string, list string) (changed int, err error) {
+	if list == "" {
+		list = DefaultList
+	}
+	return tx.updateAll(ids, func(t *Todo) bool {
+		if t.List == list {
+			return false
+		}
+		t.List = list
+		return true
+	})
+}
+
+// updateAll applies fn to all given todos in a single index batch.
+// fn returns false if it didn't change the todo.
+func (tx *Tx) updateAll(ids []string, fn func(*Todo) bool) (changed int, err error) {
+	if !tx.writable {
+		return 0, ErrReadOnly
+	}
+	s := tx.s
 
-	if err := s.index.Delete(id); err != nil {
+	now := time.Now()
+	indexes := s.findAll(ids)
+	todos := make([]Todo, 0, len(indexes))
+	var updated []Event
+	for _, i := range indexes {
+		prev := s.todos[i]
+		t := prev
+		if !fn(&t) {
+			continue
+		}
+		t.Version++
+		if err := tx.index(t); err != nil {
+			return 0, err
+		}
+		todos = append(todos, t)
+		updated = append(updated, Event{Type: EventUpdated, Todo: t, Previous: prev, Time: now})
+	}
+	if len(todos) < 1 {
+		return 0, nil
+	}
+	for _, t := range todos {
+		s.setTodo(s.findByID(t.ID), t)
+	}
+	tx.record(updated...)
+	return len(todos), nil
+}
+
+// RemoveAll removes all given todos in a single index batch.
+// Unknown IDs are skipped. Returns the number of todos removed.
+func (s *Repository) RemoveAll(ids []string) (removed int, err error) {
+	err = s.Update(func(tx *Tx) error {
+		removed, err = tx.RemoveAll(ids)
 		return err
+	})
+	return removed, err
+}
+
+// RemoveAll is Repository.RemoveAll within the transaction.
+func (tx *Tx) RemoveAll(ids []string) (removed int, err error) {
+	if !tx.writable {
+		return 0, ErrReadOnly
+	}
+	s := tx.s
+
+	indexes := s.findAll(ids)
+	if len(indexes) < 1 {
+		return 0, nil
+	}
+	for _, i := range indexes {
+		tx.delete(s.todos[i].ID)
 	}
-	s.todos = append(s.todos[:i], s.todos[i+1:]...)
+	now := time.Now()
+	var deleted []Event
+	for _, i := range indexes {
+		deleted = append(deleted, Event{Type: EventDeleted, Todo: s.todos[i], Time: now})
+	}
+	s.removeTodos(indexes...)
+	tx.record(deleted...)
+	return len(indexes), nil
+}
+
+// findAll returns the indexes of the todos with the given IDs
+// in the order of ids, skipping unknown and duplicate IDs.
+// Must be called with s.lock held.
+func (s *Repository) findAll(ids []string) []int {
+	indexes := make([]int, 0, len(ids))
+	seen := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		if i := s.findByID(id); i >= 0 && !seen[i] {
+			seen[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// nextOccurrence returns the todo following the completed recurring todo t.
+// The next due date is computed from the previous due date,
+// or from now if t has none. ok is false if there are no further occurrences.
+func nextOccurrence(t Todo, now time.Time) (next Todo, ok bool, err error) {
+	r, err := ParseRecurrence(t.Recurrence)
+	if err != nil {
+		return Todo{}, false, err
+	}
+	loc := time.Local
+	if t.TimeZone != "" {
+		if loc, err = time.LoadLocation(t.TimeZone); err != nil {
+			return Todo{}, false, err
+		}
+	}
+	rest, ok := r.Remaining()
+	if !ok {
+		return Todo{}, false, nil
+	}
+	prev := t.Due
+	if prev.IsZero() {
+		prev = now
+	}
+	due, ok := r.Next(prev, loc)
+	if !ok {
+		return Todo{}, false, nil
+	}
+	var remind time.Time
+	if !t.Remind.IsZero() && !t.Due.IsZero() {
+		// Keep the reminder at the same offset to the due date.
+		remind = due.Add(t.Remind.Sub(t.Due))
+	}
+	return Todo{
+		List:       t.List,
+		Title:      t.Title,
+		Created:    now,
+		Due:        due,
+		Remind:     remind,
+		Priority:   t.Priority,
+		Recurrence: rest.String(),
+		TimeZone:   t.TimeZone,
+		Parent:     t.Parent,
+		Projects:   slices.Clone(t.Projects),
+		Contexts:   slices.Clone(t.Contexts),
+		Meta:       maps.Clone(t.Meta),
+	}, true, nil
+}
+
+// Get returns the todo with the given id.
+// Returns ErrNotFound if id isn't found.
+func (s *Repository) Get(id string) (t Todo, err error) {
+	err = s.View(func(tx *Tx) error {
+		t, err = tx.Get(id)
+		return err
+	})
+	return t, err
+}
+
+// Get is Repository.Get within the transaction.
+func (tx *Tx) Get(id string) (Todo, error) {
+	i := tx.s.findByID(id)
+	if i < 0 {
+		return Todo{}, ErrNotFound
+	}
+	return tx.s.todos[i], nil
+}
+
+// GetByUID returns the todo with the given UID.
+// Returns ErrNotFound if no todo has it.
+func (tx *Tx) GetByUID(uid string) (Todo, error) {
+	i, ok := tx.s.byUID[uid]
+	if !ok {
+		return Todo{}, ErrNotFound
+	}
+	return tx.s.todos[i], nil
+}
+
+// Replace replaces the todo with ID t.ID by t.
+// Returns ErrNotFound if t.ID isn't found.
+func (s *Repository) Replace(t Todo) error {
+	return s.Update(func(tx *Tx) error { return tx.Replace(t) })
+}
+
+// Replace is Repository.Replace within the transaction.
+func (tx *Tx) Replace(t Todo) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	s := tx.s
+
+	i := s.findByID(t.ID)
+	if i < 0 {
+		return ErrNotFound
+	}
+	if err := t.validate(); err != nil {
+		return err
+	}
+	if t.List == "" {
+		t.List = DefaultList
+	}
+	prev := s.todos[i]
+	t.Position = prev.Position // Only changed by Move.
+	t.Version = prev.Version + 1
+	if err := tx.index(t); err != nil {
+		return err
+	}
+	s.setTodo(i, t)
+	now := time.Now()
+	tx.record(Event{Type: EventUpdated, Todo: t, Previous: prev, Time: now})
+	if t.Title != prev.Title {
+		tx.record(Event{Type: EventRenamed, Todo: t, Previous: prev, Time: now})
+	}
+	return nil
+}
+
+// Rename changes the title of the given todo.
+// Returns ErrNotFound if id isn't found.
+func (s *Repository) Rename(id, title string) (t Todo, err error) {
+	err = s.Update(func(tx *Tx) error {
+		t, err = tx.Rename(id, title)
+		return err
+	})
+	return t, err
+}
+
+// Rename is Repository.Rename within the transaction.
+func (tx *Tx) Rename(id, title string) (Todo, error) {
+	if !tx.writable {
+		return Todo{}, ErrReadOnly
+	}
+	s := tx.s
+
+	i := s.findByID(id)
+	if i < 0 {
+		return Todo{}, ErrNotFound
+	}
+	prev := s.todos[i]
+	if prev.Title == title {
+		return prev, nil
+	}
+	t := prev
+	t.Title = title
+	t.Version++
+	if err := tx.index(t); err != nil {
+		return Todo{}, err
+	}
+	s.setTodo(i, t)
+	now := time.Now()
+	tx.record(
+		Event{Type: EventUpdated, Todo: t, Previous: prev, Time: now},
+		Event{Type: EventRenamed, Todo: t, Previous: prev, Time: now})
+	return t, nil
+}
+
+// SetReminder sets the time the given todo should be reminded of at.
+// The zero time removes the reminder.
+// Returns ErrNotFound if id isn't found.
+func (s *Repository) SetReminder(id string, at time.Time) (t Todo, err error) {
+	err = s.Update(func(tx *Tx) error {
+		t, err = tx.SetReminder(id, at)
+		return err
+	})
+	return t, err
+}
+
+// SetReminder is Repository.SetReminder within the transaction.
+func (tx *Tx) SetReminder(id string, at time.Time) (Todo, error) {
+	if !tx.writable {
+		return Todo{}, ErrReadOnly
+	}
+	s := tx.s
+
+	i := s.findByID(id)
+	if i < 0 {
+		return Todo{}, ErrNotFound
+	}
+	prev := s.todos[i]
+	t := prev
+	t.Remind = at
+	t.Version++
+	if err := tx.index(t); err != nil {
+		return Todo{}, err
+	}
+	s.setTodo(i, t)
+	tx.record(Event{Type: EventUpdated, Todo: t, Previous: prev, Time: time.Now()})
+	return t, nil
+}
+
+// PendingReminders returns all undone todos with a reminder at or before now
+// and the time of the earliest reminder after now, which is zero if there is none.
+func (s *Repository) PendingReminders(now time.Time) (due []Todo, next time.Time) {
+	_ = s.View(func(tx *Tx) error {
+		due, next = tx.PendingReminders(now)
+		return nil
+	})
+	return due, next
+}
+
+// PendingReminders is Repository.PendingReminders within the transaction.
+func (tx *Tx) PendingReminders(now time.Time) (due []Todo, next time.Time) {
+	for _, t := range tx.s.todos {
+		switch {
+		case t.Done || t.Remind.IsZero():
+		case !t.Remind.After(now):
+			due = append(due, t)
+		case next.IsZero() || t.Remind.Before(next):
+			next = t.Remind
+		}
+	}
+	return due, next
+}
+
+// ClearReminder removes the reminder of the given todo unless
+// it was changed to anything other than at in the meantime.
+// No-op if id doesn't exist.
+func (s *Repository) ClearReminder(id string, at time.Time) error {
+	return s.Update(func(tx *Tx) error { return tx.ClearReminder(id, at) })
+}
+
+// ClearReminder is Repository.ClearReminder within the transaction.
+func (tx *Tx) ClearReminder(id string, at time.Time) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	s := tx.s
+
+	i := s.findByID(id)
+	if i < 0 || !s.todos[i].Remind.Equal(at) {
+		return nil
+	}
+	prev := s.todos[i]
+	t := prev
+	t.Remind = time.Time{}
+	t.Version++
+	if err := tx.index(t); err != nil {
+		return err
+	}
+	s.setTodo(i, t)
+	tx.record(Event{Type: EventUpdated, Todo: t, Previous: prev, Time: time.Now()})
+	return nil
+}
+
+// Move moves the given todo between the todos after and before,
+// either of which may be empty to move it to the top or bottom.
+// If after and before aren't adjacent anymore (e.g. because of a concurrent
+// move), the todo is placed directly after after, or before before if
+// after is empty.
+// Returns ErrNotFound if any of the IDs isn't found.
+func (s *Repository) Move(id, after, before string) (t Todo, err error) {
+	err = s.Update(func(tx *Tx) error {
+		t, err = tx.Move(id, after, before)
+		return err
+	})
+	return t, err
+}
+
+// Move is Repository.Move within the transaction.
+func (tx *Tx) Move(id, after, before string) (Todo, error) {
+	if !tx.writable {
+		return Todo{}, ErrReadOnly
+	}
+	s := tx.s
+
+	i := s.findByID(id)
+	if i < 0 || id == after || id == before {
+		return Todo{}, ErrNotFound
+	}
+	// Sorted positions of all other todos.
+	positions := make([]string, 0, len(s.todos))
+	for j := range s.todos {
+		if j != i {
+			positions = append(positions, s.todos[j].Position)
+		}
+	}
+	slices.Sort(positions)
+
+	var lower, upper string
+	if after != "" {
+		a := s.findByID(after)
+		if a < 0 {
+			return Todo{}, ErrNotFound
+		}
+		lower = s.todos[a].Position
+	}
+	if before != "" {
+		b := s.findByID(before)
+		if b < 0 {
+			return Todo{}, ErrNotFound
+		}
+		upper = s.todos[b].Position
+	}
+	switch {
+	case after != "":
+		// The neighbour directly after lower.
+		j, _ := slices.BinarySearch(positions, lower)
+		for j < len(positions) && positions[j] <= lower {
+			j++
+		}
+		if upper == "" || j >= len(positions) || positions[j] != upper {
+			upper = ""
+			if j < len(positions) {
+				upper = positions[j]
+			}
+		}
+	case before != "":
+		// The neighbour directly before upper.
+		j, _ := slices.BinarySearch(positions, upper)
+		if j > 0 {
+			lower = positions[j-1]
+		}
+	default:
+		if len(positions) > 0 {
+			upper = positions[0]
+		}
+	}
+
+	pos, err := positionBetween(lower, upper)
+	if err != nil {
+		return Todo{}, fmt.Errorf("computing position: %w", err)
+	}
+	prev := s.todos[i]
+	t := prev
+	t.Position = pos
+	t.Version++
+	if err := tx.index(t); err != nil {
+		return Todo{}, err
+	}
+	s.setTodo(i, t)
+	tx.record(Event{Type: EventUpdated, Todo: t, Previous: prev, Time: time.Now()})
+	return t, nil
+}
+
+// firstPosition returns the smallest position or "" if there are no todos.
+// It's kept up to date by setTodo, appendTodos and removeTodos and only
+// recomputed when the todo at the first position was moved or removed.
+// Must be called with s.lock held for writing.
+func (s *Repository) firstPosition() string {
+	if s.firstValid {
+		return s.first
+	}
+	s.first = ""
+	for i := range s.todos {
+		if p := s.todos[i].Position; s.first == "" || p < s.first {
+			s.first = p
+		}
+	}
+	s.firstValid = true
+	return s.first
+}
+
+// positionFirst returns a position before all todos.
+// Must be called with s.lock held for writing.
+func (s *Repository) positionFirst() (string, error) {
+	return positionBetween("", s.firstPosition())
+}
+
+// Remove removes a todo item. No-op if id doesn't exist.
+func (s *Repository) Remove(id string) error {
+	return s.Update(func(tx *Tx) error { return tx.Remove(id) })
+}
+
+// Remove is Repository.Remove within the transaction.
+func (tx *Tx) Remove(id string) error {
+	if !tx.writable {
+		return ErrReadOnly
+	}
+	s := tx.s
+
+	i := s.findByID(id)
+	if i < 0 {
+		return nil
+	}
+
+	tx.delete(id)
+	t := s.todos[i]
+	s.removeTodos(i)
+	tx.record(Event{Type: EventDeleted, Todo: t, Time: time.Now()})
 	return nil
 }
 
 // All calls retuens all stored todo sorted by index DESC.
-func (s *Repository) All() ([]Todo, error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// Archived todos are excluded.
+func (s *Repository) All() (todos []Todo, err error) {
+	err = s.View(func(tx *Tx) error {
+		todos, err = tx.All()
+		return err
+	})
+	return todos, err
+}
+
+// All is Repository.All within the transaction.
+func (tx *Tx) All() ([]Todo, error) { return tx.s.todosAt(tx.s.sorted(false)), nil }
+
+// Progress returns the number of done and all todos, excluding archived todos.
+func (s *Repository) Progress() (done, total int) {
+	_ = s.View(func(tx *Tx) error { done, total = tx.Progress(); return nil })
+	return done, total
+}
+
+// Progress is Repository.Progress within the transaction.
+func (tx *Tx) Progress() (done, total int) {
+	s := tx.s
+	for i := range s.todos {
+		if s.todos[i].Archived {
+			continue
+		}
+		total++
+		if s.todos[i].Done {
+			done++
+		}
+	}
+	return done, total
+}
 
-	cp := make([]Todo, len(s.todos))
-	copy(cp, s.todos)
-	slices.Reverse(cp) // Newest first
-	return cp, nil
+// Archived returns all archived todos sorted by position.
+func (s *Repository) Archived() (todos []Todo, err error) {
+	err = s.View(func(tx *Tx) error {
+		todos, err = tx.Archived()
+		return err
+	})
+	return todos, err
 }
 
-// Find calls fn for every item that matches term,
-// unless fn returns an error, in which case Find returns immediately.
-func (s *Repository) Find(term string) ([]Todo, error) {
+// Archived is Repository.Archived within the transaction.
+func (tx *Tx) Archived() ([]Todo, error) { return tx.s.todosAt(tx.s.sorted(true)), nil }
+
+// sorted returns the indexes of the todos with the given archive state
+// in s.todos sorted by position. The result is cached in s.order
+// and must not be modified.
+// Must be called with s.lock held.
+func (s *Repository) sorted(archived bool) []int {
+	k := archivedKey(archived)
+	// Readers share s.lock, the cache has its own lock.
+	s.orderLock.Lock()
+	defer s.orderLock.Unlock()
+	if s.order[k] != nil {
+		return s.order[k]
+	}
+	o := []int{}
+	for i := len(s.todos) - 1; i >= 0; i-- { // Newest first for equal positions.
+		if s.todos[i].Archived == archived {
+			o = append(o, i)
+		}
+	}
+	slices.SortStableFunc(o, func(a, b int) int {
+		return strings.Compare(s.todos[a].Position, s.todos[b].Position)
+	})
+	s.order[k] = o
+	return o
+}
+
+func archivedKey(archived bool) int {
+	if archived {
+		return 1
+	}
+	return 0
+}
+
+// todosAt returns the todos at the given indexes.
+// Must be called with s.lock held.
+func (s *Repository) todosAt(indexes []int) []Todo {
+	todos := make([]Todo, len(indexes))
+	for j, i := range indexes {
+		todos[j] = s.todos[i]
+	}
+	return todos
+}
+
+// Lists returns the names of all lists sorted alphabetically.
+// DefaultList is always included.
+func (s *Repository) Lists() (names []string) {
+	_ = s.View(func(tx *Tx) error { names = tx.Lists(); return nil })
+	return names
+}
+
+// Lists is Repository.Lists within the transaction.
+func (tx *Tx) Lists() []string {
+	s := tx.s
+	names := []string{DefaultList}
+	for i := range s.todos {
+		if !slices.Contains(names, s.todos[i].List) {
+			names = append(names, s.todos[i].List)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// InList returns all todos of the given list sorted by index ASC.
+func (s *Repository) InList(list string) (todos []Todo, err error) {
+	err = s.View(func(tx *Tx) error {
+		todos, err = tx.InList(list)
+		return err
+	})
+	return todos, err
+}
+
+// InList is Repository.InList within the transaction.
+func (tx *Tx) InList(list string) ([]Todo, error) {
+	s := tx.s
+	var r []Todo
+	for i := range s.todos {
+		if s.todos[i].List == list {
+			r = append(r, s.todos[i])
+		}
+	}
+	return r, nil
+}
+
+// Find returns the todos that match the query term (see ParseQuery).
+// Archived todos are excluded.
+// Returns a *QueryError if term is invalid and ctx.Err()
+// if ctx is canceled before the search is done.
+func (s *Repository) Find(ctx context.Context, term string) (todos []Todo, err error) {
+	err = s.View(func(tx *Tx) error {
+		todos, err = tx.Find(ctx, term)
+		return err
+	})
+	return todos, err
+}
+
+// Find is Repository.Find within the transaction.
+func (tx *Tx) Find(ctx context.Context, term string) ([]Todo, error) {
+	return tx.s.find(ctx, term, false)
+}
+
+// FindArchived returns the archived todos that match term.
+func (s *Repository) FindArchived(
+	ctx context.Context, term string,
+) (todos []Todo, err error) {
+	err = s.View(func(tx *Tx) error {
+		todos, err = tx.FindArchived(ctx, term)
+		return err
+	})
+	return todos, err
+}
+
+// FindArchived is Repository.FindArchived within the transaction.
+func (tx *Tx) FindArchived(ctx context.Context, term string) ([]Todo, error) {
+	return tx.s.find(ctx, term, true)
+}
+
+// SetFuzziness sets the default edit distance of search terms,
+// from 0 (disabled) to MaxFuzziness. The default is DefaultFuzziness.
+func (s *Repository) SetFuzziness(n int) error {
+	if n < 0 || n > MaxFuzziness {
+		return fmt.Errorf("invalid fuzziness %d, expected 0 to %d", n, MaxFuzziness)
+	}
 	s.lock.Lock()
 	defer s.lock.Unlock()
+	s.fuzziness = n
+	return nil
+}
+
+// find must be called with s.lock held.
+func (s *Repository) find(ctx context.Context, term string, archived bool) ([]Todo, error) {
+	p, err := s.search(ctx, term, searchOptions{
+		archived: archived,
+		sort:     SortRelevance,
+		size:     max(len(s.todos), 1),
+	})
+	return p.Todos, err
+}
+
+// searchOptions configures search.
+type searchOptions struct {
+	archived   bool
+	sort       SortOrder
+	from, size int
+	// highlight requests Page.Highlights.
+	highlight bool
+	// facets requests Page.Facets.
+	facets bool
+}
 
-	disj := bleve.NewDisjunctionQuery(
-		bleve.NewPrefixQuery(term),
-		bleve.NewTermQuery(term),
-	)
-	req := bleve.NewSearchRequest(disj)
-	res, err := s.index.Search(req)
+// search returns the page of size todos matching term in sort order
+// starting at from. Next and Prev of the page are left empty.
+// The search is aborted when ctx is canceled.
+// Must be called with s.lock held.
+func (s *Repository) search(ctx context.Context, term string, o searchOptions) (Page, error) {
+	match, err := ParseQuery(term, QueryOptions{
+		Fuzziness: s.fuzziness,
+		Languages: s.languages.used(),
+	})
 	if err != nil {
-		return nil, err
+		return Page{}, err
+	}
+	isArchived := bleve.NewBoolFieldQuery(true)
+	isArchived.SetField("archived")
+	q := bleve.NewBooleanQuery()
+	q.AddMust(match)
+	if o.archived {
+		q.AddMust(isArchived)
+	} else {
+		// Documents indexed before archiving existed lack the field.
+		q.AddMustNot(isArchived)
+	}
+	if boosts := s.boosts.queries(term); len(boosts) > 0 {
+		q.AddShould(boosts...)
+	}
+	req := bleve.NewSearchRequestOptions(q, o.size, o.from, false)
+	req.SortByCustom(o.sort.sortBy())
+	if o.highlight {
+		req.Highlight = bleve.NewHighlightWithStyle(highlighterSegments)
+		req.Highlight.AddField("title")
+	}
+	now := time.Now()
+	if o.facets {
+		addFacetRequests(req, now)
+	}
+	res, err := s.index.SearchInContext(ctx, req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return Page{}, ctx.Err()
+		}
+		return Page{}, err
 	}
-	r := make([]Todo, len(res.Hits))
 
-	for i := range res.Hits {
-		r[i] = s.todos[s.findByID(res.Hits[i].ID)]
+	p := Page{Todos: make([]Todo, len(res.Hits)), Total: int(res.Total)}
+	if o.highlight {
+		p.Highlights = make(map[string][]Segment, len(res.Hits))
 	}
-	return r, nil
+	for i, hit := range res.Hits {
+		p.Todos[i] = s.todos[s.findByID(hit.ID)]
+		if f := hit.Fragments["title"]; o.highlight && len(f) > 0 {
+			p.Highlights[hit.ID] = parseSegments(f[0])
+		}
+	}
+	if o.facets {
+		p.Facets = facets(res.Facets, now)
+	}
+	return p, nil
 }
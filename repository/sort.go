@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis"
+	"github.com/blevesearch/bleve/v2/analysis/token/lowercase"
+	"github.com/blevesearch/bleve/v2/analysis/tokenizer/single"
+	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/blevesearch/bleve/v2/search"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// SortOrder is the order of search results.
+type SortOrder string
+
+const (
+	// SortRelevance sorts the best matches first.
+	SortRelevance SortOrder = "relevance"
+	// SortNewest sorts the most recently created todos first.
+	SortNewest SortOrder = "newest"
+	// SortDue sorts by due date, todos without one last.
+	SortDue SortOrder = "due"
+	// SortPriority sorts by priority, todos without one last.
+	SortPriority SortOrder = "priority"
+)
+
+// SortOrders are all supported sort orders.
+var SortOrders = []SortOrder{SortRelevance, SortNewest, SortDue, SortPriority}
+
+// Valid returns true if o is one of SortOrders.
+func (o SortOrder) Valid() bool { return slices.Contains(SortOrders, o) }
+
+func (o SortOrder) String() string {
+	switch o {
+	case SortRelevance:
+		return "Relevance"
+	case SortNewest:
+		return "Newest"
+	case SortDue:
+		return "Due date"
+	case SortPriority:
+		return "Priority"
+	}
+	return string(o)
+}
+
+// sortBy returns the bleve sort order of o. Ties are broken
+// by relevance, creation time and ID for a stable order across pages.
+func (o SortOrder) sortBy() search.SortOrder {
+	score := &search.SortScore{Desc: true}
+	created := &search.SortField{
+		Field: "created", Type: search.SortFieldAsDate, Desc: true,
+	}
+	id := &search.SortDocID{}
+	switch o {
+	case SortNewest:
+		return search.SortOrder{created, score, id}
+	case SortDue:
+		// Zero due dates aren't indexed and thus missing.
+		due := &search.SortField{
+			Field: "due", Type: search.SortFieldAsDate, Missing: search.SortFieldMissingLast,
+		}
+		return search.SortOrder{due, score, created, id}
+	case SortPriority:
+		priority := &search.SortField{Field: "priorityRank", Type: search.SortFieldAsNumber}
+		return search.SortOrder{priority, score, created, id}
+	}
+	return search.SortOrder{score, created, id}
+}
+
+// Boosts raise the relevance of some matches when sorting by SortRelevance
+// and among equal keys of the other sort orders.
+type Boosts struct {
+	// Open ranks open todos above done ones.
+	Open bool
+	// ExactTitle ranks todos whose title equals the search term first.
+	ExactTitle bool
+}
+
+const (
+	boostOpen       = 2
+	boostExactTitle = 10
+)
+
+// SetBoosts sets the boosts of search results, all disabled by default.
+func (s *Repository) SetBoosts(b Boosts) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.boosts = b
+}
+
+// queries returns the optional clauses boosting matches of term.
+func (b Boosts) queries(term string) []query.Query {
+	var r []query.Query
+	if b.Open {
+		open := bleve.NewBoolFieldQuery(false)
+		open.SetField("done")
+		open.SetBoost(boostOpen)
+		r = append(r, open)
+	}
+	if b.ExactTitle {
+		exact := bleve.NewTermQuery(exactTitle(strings.Trim(strings.TrimSpace(term), `"`)))
+		exact.SetField("titleExact")
+		exact.SetBoost(boostExactTitle)
+		r = append(r, exact)
+	}
+	return r
+}
+
+// analyzerExact is the name of the analyzer of titleExact,
+// which indexes the lower case and ASCII folded title as a single term.
+const analyzerExact = "todo-exact"
+
+func init() {
+	registry.RegisterAnalyzer(analyzerExact,
+		func(_ map[string]any, cache *registry.Cache) (analysis.Analyzer, error) {
+			tokenizer, err := cache.TokenizerNamed(single.Name)
+			if err != nil {
+				return nil, err
+			}
+			toLower, err := cache.TokenFilterNamed(lowercase.Name)
+			if err != nil {
+				return nil, err
+			}
+			folding, err := cache.TokenFilterNamed(tokenFilterASCIIFolding)
+			if err != nil {
+				return nil, err
+			}
+			return &analysis.DefaultAnalyzer{
+				Tokenizer:    tokenizer,
+				TokenFilters: []analysis.TokenFilter{toLower, folding},
+			}, nil
+		})
+}
+
+// exactTitle returns the term title is indexed as in titleExact.
+func exactTitle(title string) string {
+	a, err := analyzers.AnalyzerNamed(analyzerExact)
+	if err != nil {
+		// Unreachable, the analyzer is registered in init.
+		panic(err)
+	}
+	tokens := a.Analyze([]byte(title))
+	if len(tokens) < 1 {
+		return ""
+	}
+	return string(tokens[0].Term)
+}
+
+// priorityRank orders priorities from A to Z followed by PriorityNone.
+func priorityRank(p Priority) int {
+	if p == PriorityNone {
+		return 'Z' + 1
+	}
+	return int(p)
+}
@@ -0,0 +1,86 @@
+package repository_test
+
+import (
+	"context"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+func TestFindPageSortOrders(t *testing.T) {
+	const n = 23
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	todos := make([]repository.Todo, n)
+	for i := range todos {
+		// Many todos share their sort keys to test the tie-breakers.
+		todos[i] = repository.Todo{
+			Title:   "Todo " + strconv.Itoa(i),
+			Created: now.Add(-time.Duration(i%4) * time.Hour),
+		}
+		if i%3 > 0 {
+			todos[i].Due = now.AddDate(0, 0, i%3)
+		}
+		if i%5 > 1 {
+			todos[i].Priority = repository.Priority('A' + i%5 - 2)
+		}
+	}
+	r := newRepository(t)
+	if _, err := r.Import(todos, now); err != nil {
+		t.Fatal(err)
+	}
+
+	// inOrder reports whether a may be listed before b by the primary key.
+	inOrder := map[repository.SortOrder]func(a, b repository.Todo) bool{
+		repository.SortRelevance: func(a, b repository.Todo) bool { return true },
+		repository.SortNewest: func(a, b repository.Todo) bool {
+			return !a.Created.Before(b.Created)
+		},
+		repository.SortDue: func(a, b repository.Todo) bool {
+			return b.Due.IsZero() || (!a.Due.IsZero() && !a.Due.After(b.Due))
+		},
+		repository.SortPriority: func(a, b repository.Todo) bool {
+			return b.Priority == repository.PriorityNone ||
+				(a.Priority != repository.PriorityNone && a.Priority <= b.Priority)
+		},
+	}
+	for _, sort := range repository.SortOrders {
+		t.Run(string(sort), func(t *testing.T) {
+			find := func(cursor string, limit int) repository.Page {
+				t.Helper()
+				p, err := r.FindPage(context.Background(), "todo", sort, cursor, limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return p
+			}
+			all := find("", n)
+			if len(all.Todos) != n {
+				t.Fatalf("%d results, want %d", len(all.Todos), n)
+			}
+			if unique := slices.Compact(slices.Sorted(slices.Values(ids(all.Todos)))); len(unique) != n {
+				t.Fatalf("duplicate results %v", ids(all.Todos))
+			}
+			for i := 1; i < n; i++ {
+				if a, b := all.Todos[i-1], all.Todos[i]; !inOrder[sort](a, b) {
+					t.Errorf("%s listed before %s", a.Title, b.Title)
+				}
+			}
+
+			for _, limit := range []int{1, 4, 5} {
+				var paged []repository.Todo
+				for p := find("", limit); ; p = find(p.Next, limit) {
+					paged = append(paged, p.Todos...)
+					if p.Next == "" {
+						break
+					}
+				}
+				if got, want := ids(paged), ids(all.Todos); !slices.Equal(got, want) {
+					t.Errorf("pages of %d: %v, want %v", limit, got, want)
+				}
+			}
+		})
+	}
+}
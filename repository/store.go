@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+// SchemaVersion is the current version of the store file schema.
+// Any change to the JSON representation of the store file
+// requires a new migration (see migrations).
+const SchemaVersion = 5
+
+// IndexVersion is the current version of the search index documents and mapping.
+// Indexes of other versions are rebuilt by Open.
+const IndexVersion = "2"
+
+// internalIndexVersion is the key of the index version
+// in the internal storage of the search index.
+const internalIndexVersion = "todo-index-version"
+
+// Names of the files and directories within the data directory.
+const (
+	FileStore = "todos.json"
+	DirIndex  = "index.bleve"
+)
+
+// storeFile is the JSON representation of the store file.
+type storeFile struct {
+	Version   int    `json:"version"`
+	IDCounter uint64 `json:"idCounter"`
+	Todos     []Todo `json:"todos"`
+
+	Languages languageSettings `json:"languages"`
+}
+
+// Open opens the repository persisted in dir creating it if it doesn't exist yet.
+// Stores of earlier schema versions are migrated first.
+// The search index is rebuilt from the store if it's missing, out of sync
+// or of a version other than IndexVersion.
+func Open(dir string) (*Repository, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating data directory: %w", err)
+	}
+
+	if _, err := Migrate(dir, false); err != nil {
+		return nil, fmt.Errorf("migrating store: %w", err)
+	}
+
+	var f storeFile
+	storeExists, err := readStoreFile(filepath.Join(dir, FileStore), &f)
+	if err != nil {
+		return nil, err
+	}
+
+	pathIndex := filepath.Join(dir, DirIndex)
+	index, err := bleve.Open(pathIndex)
+	switch {
+	case errors.Is(err, bleve.ErrorIndexPathDoesNotExist):
+		index, err = newIndex(pathIndex, f.Todos, f.Languages)
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("opening bleve index: %w", err)
+	default:
+		count, err := index.DocCount()
+		if err != nil {
+			_ = index.Close()
+			return nil, fmt.Errorf("counting indexed documents: %w", err)
+		}
+		version, err := index.GetInternal([]byte(internalIndexVersion))
+		if err != nil {
+			_ = index.Close()
+			return nil, fmt.Errorf("reading index version: %w", err)
+		}
+		if !storeExists || count != uint64(len(f.Todos)) ||
+			string(version) != IndexVersion {
+			// The index is out of sync with the store or outdated.
+			if err := index.Close(); err != nil {
+				return nil, fmt.Errorf("closing stale bleve index: %w", err)
+			}
+			if err := os.RemoveAll(pathIndex); err != nil {
+				return nil, fmt.Errorf("removing stale bleve index: %w", err)
+			}
+			if index, err = newIndex(pathIndex, f.Todos, f.Languages); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &Repository{
+		dir:       dir,
+		index:     index,
+		idCounter: f.IDCounter,
+		todos:     f.Todos,
+		byID:      indexByID(f.Todos),
+		byUID:     indexByUID(f.Todos),
+		fuzziness: DefaultFuzziness,
+		languages: f.Languages,
+	}, nil
+}
+
+// readStoreFile returns exists=false if there's no store file at path.
+func readStoreFile(path string, f *storeFile) (exists bool, err error) {
+	b, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		f.Version = SchemaVersion
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("reading store file: %w", err)
+	}
+	if err := json.Unmarshal(b, f); err != nil {
+		return false, fmt.Errorf("decoding store file: %w", err)
+	}
+	if f.Version != SchemaVersion {
+		return false, fmt.Errorf(
+			"unsupported store schema version %d, expected %d", f.Version, SchemaVersion,
+		)
+	}
+	return true, nil
+}
+
+// newIndex creates a new on-disk bleve index at path containing todos
+// analyzed in their languages.
+func newIndex(path string, todos []Todo, languages languageSettings) (bleve.Index, error) {
+	index, err := bleve.New(path, newIndexMapping())
+	if err != nil {
+		return nil, fmt.Errorf("creating new bleve index: %w", err)
+	}
+	b := index.NewBatch()
+	for _, t := range todos {
+		if err := b.Index(t.ID, newIndexedTodo(t, languages.of(t.List))); err != nil {
+			_ = index.Close()
+			return nil, fmt.Errorf("indexing todo %q: %w", t.ID, err)
+		}
+	}
+	if err := index.Batch(b); err != nil {
+		_ = index.Close()
+		return nil, fmt.Errorf("indexing todos: %w", err)
+	}
+	err = index.SetInternal([]byte(internalIndexVersion), []byte(IndexVersion))
+	if err != nil {
+		_ = index.Close()
+		return nil, fmt.Errorf("writing index version: %w", err)
+	}
+	return index, nil
+}
+
+// persist atomically writes the store file. No-op for in-memory repositories.
+// Must be called with s.lock held.
+func (s *Repository) persist() error {
+	if s.dir == "" {
+		return nil
+	}
+	b, err := s.marshalStore()
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(filepath.Join(s.dir, FileStore), b)
+}
+
+// marshalStore must be called with s.lock held.
+func (s *Repository) marshalStore() ([]byte, error) {
+	b, err := json.Marshal(storeFile{
+		Version:   SchemaVersion,
+		IDCounter: s.idCounter,
+		Todos:     s.todos,
+		Languages: s.languages,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encoding store file: %w", err)
+	}
+	return b, nil
+}
+
+// WriteFileAtomic writes data to a temporary file and renames it to path
+// to never leave a partially written file behind.
+func WriteFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("creating temporary file: %w", err)
+	}
+	defer os.Remove(f.Name()) // No-op after successful rename.
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing temporary file: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("syncing temporary file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("closing temporary file: %w", err)
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return fmt.Errorf("renaming temporary file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// Suggestions are completions of a search term prefix.
+type Suggestions struct {
+	// Titles are the distinct titles of the todos best matching the prefix.
+	Titles []string
+	// Tags are the context (#) and project (+) tags starting with
+	// the last word of the prefix, most used first.
+	Tags []string
+}
+
+// Suggest returns up to limit titles and tags completing prefix.
+// Titles aren't suggested while prefix is an invalid query,
+// e.g. before the closing quote of a phrase is typed.
+// Returns ctx.Err() if ctx is canceled.
+func (s *Repository) Suggest(
+	ctx context.Context, prefix string, limit int,
+) (r Suggestions, err error) {
+	err = s.View(func(tx *Tx) error {
+		r, err = tx.Suggest(ctx, prefix, limit)
+		return err
+	})
+	return r, err
+}
+
+// Suggest is Repository.Suggest within the transaction.
+func (tx *Tx) Suggest(ctx context.Context, prefix string, limit int) (Suggestions, error) {
+	var r Suggestions
+	if strings.TrimSpace(prefix) == "" || limit < 1 {
+		return r, nil
+	}
+	s := tx.s
+
+	p, err := s.search(ctx, prefix, searchOptions{sort: SortRelevance, size: limit})
+	var qe *QueryError
+	if err != nil && !errors.As(err, &qe) {
+		return Suggestions{}, err
+	}
+	for _, t := range p.Todos {
+		if !slices.Contains(r.Titles, t.Title) {
+			r.Titles = append(r.Titles, t.Title)
+		}
+	}
+
+	word, ok := tagPrefix(prefix)
+	if !ok {
+		return r, nil
+	}
+	type tag struct {
+		name  string
+		count uint64
+	}
+	var tags []tag
+	for _, f := range []struct{ field, sigil string }{
+		{"contexts", "#"}, {"projects", "+"},
+	} {
+		// Walk the term dictionary of the tag field.
+		d, err := s.index.FieldDictPrefix(f.field, []byte(word))
+		if err != nil {
+			return Suggestions{}, err
+		}
+		for {
+			e, err := d.Next()
+			if err != nil {
+				_ = d.Close()
+				return Suggestions{}, err
+			}
+			if e == nil {
+				break
+			}
+			if e.Count > 0 {
+				tags = append(tags, tag{name: f.sigil + e.Term, count: e.Count})
+			}
+		}
+		if err := d.Close(); err != nil {
+			return Suggestions{}, err
+		}
+	}
+	slices.SortStableFunc(tags, func(a, b tag) int {
+		switch {
+		case a.count > b.count:
+			return -1
+		case a.count < b.count:
+			return 1
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	for _, t := range tags[:min(len(tags), limit)] {
+		r.Tags = append(r.Tags, t.name)
+	}
+	return r, nil
+}
+
+// tagPrefix returns the lower case beginning of a tag
+// the last word of prefix is, like "ho" for "tag:ho", "#ho" or "ho".
+// Returns ok=false if prefix ends with a space or another qualifier.
+func tagPrefix(prefix string) (word string, ok bool) {
+	if prefix == "" || unicode.IsSpace(rune(prefix[len(prefix)-1])) {
+		return "", false
+	}
+	fields := strings.Fields(prefix)
+	word = strings.TrimPrefix(fields[len(fields)-1], "-")
+	word = strings.TrimPrefix(word, "tag:")
+	if strings.ContainsAny(word, `:"`) {
+		return "", false
+	}
+	word = strings.ToLower(strings.TrimLeft(word, "#+@"))
+	return word, word != ""
+}
@@ -0,0 +1,147 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/blevesearch/bleve/v2"
+)
+
+var ErrReadOnly = errors.New("write in a read-only transaction")
+
+// Tx is a transaction passed to the function given to View or Update.
+// All reads within a transaction see the same state. A Tx must not be
+// used after the function returns and must not call methods of the
+// Repository, which would deadlock.
+type Tx struct {
+	s        *Repository
+	writable bool
+	// changed is true once a write was applied.
+	changed bool
+	events  []Event
+	// batch collects the changes of the search index,
+	// which are applied once the transaction succeeds.
+	batch *bleve.Batch
+	// indexed are the IDs of all todos in batch.
+	indexed map[string]bool
+}
+
+// View calls fn with a read-only transaction. Writes are blocked until fn
+// returns, other reads can run in parallel. Writes within fn return ErrReadOnly.
+func (s *Repository) View(fn func(tx *Tx) error) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return fn(&Tx{s: s})
+}
+
+// Update calls fn with a read-write transaction. All other reads and writes
+// are blocked until fn returns. The changes are applied to the search index
+// in a single batch and persisted once fn returns, their events are emitted
+// afterwards. If fn returns an error, or applying or persisting the changes
+// fails, all changes are rolled back and no events are emitted.
+// Searches within fn don't see the changes made before within fn.
+func (s *Repository) Update(fn func(tx *Tx) error) error {
+	tx := &Tx{
+		s:        s,
+		writable: true,
+		batch:    s.index.NewBatch(),
+		indexed:  map[string]bool{},
+	}
+	defer s.emit(&tx.events)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	prev := s.state()
+	s.journal = []func(){}
+	defer func() { s.journal = nil }()
+
+	err := fn(tx)
+	if err == nil && tx.batch.Size() > 0 {
+		if err = s.index.Batch(tx.batch); err != nil {
+			err = fmt.Errorf("updating search index: %w", err)
+		}
+	}
+	if err == nil && tx.changed {
+		if err = s.persist(); err != nil {
+			// The index was already updated and must be reverted too.
+			s.rollback(prev)
+			if rerr := tx.revertIndex(); rerr != nil {
+				err = errors.Join(err, fmt.Errorf("reverting search index: %w", rerr))
+			}
+			tx.events = nil
+			return err
+		}
+	}
+	if err != nil {
+		s.rollback(prev)
+		tx.events = nil
+		return err
+	}
+	return nil
+}
+
+// state are the fields of a Repository restored by rollback
+// except for the todos, which are restored by s.journal.
+type state struct {
+	idCounter uint64
+	history   []historyEntry
+	languages languageSettings
+}
+
+// state must be called with s.lock held.
+func (s *Repository) state() state {
+	return state{
+		idCounter: s.idCounter,
+		history:   slices.Clone(s.history),
+		languages: s.languages,
+	}
+}
+
+// rollback reverts all changes of the running Update to the state
+// it began with. Must be called with s.lock held for writing.
+func (s *Repository) rollback(prev state) {
+	for _, revert := range slices.Backward(s.journal) {
+		revert()
+	}
+	s.journal = s.journal[:0]
+	s.idCounter, s.history, s.languages = prev.idCounter, prev.history, prev.languages
+	s.byID, s.byUID = indexByID(s.todos), indexByUID(s.todos)
+	s.firstValid = false
+	s.order = [2][]int{}
+}
+
+// revertIndex indexes the current state of all todos in tx.batch,
+// which reverts the batch after rollback.
+func (tx *Tx) revertIndex() error {
+	s := tx.s
+	b := s.index.NewBatch()
+	for id := range tx.indexed {
+		if i := s.findByID(id); i >= 0 {
+			if err := b.Index(id, s.document(s.todos[i])); err != nil {
+				return err
+			}
+		} else {
+			b.Delete(id)
+		}
+	}
+	return s.index.Batch(b)
+}
+
+// index adds t to the search index once the transaction succeeds.
+func (tx *Tx) index(t Todo) error {
+	tx.indexed[t.ID] = true
+	return tx.batch.Index(t.ID, tx.s.document(t))
+}
+
+// delete removes the todo from the search index once the transaction succeeds.
+func (tx *Tx) delete(id string) {
+	tx.indexed[id] = true
+	tx.batch.Delete(id)
+}
+
+// record marks tx as changed and records the events of the change.
+func (tx *Tx) record(events ...Event) {
+	tx.changed = true
+	tx.events = append(tx.events, events...)
+}
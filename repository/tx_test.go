@@ -0,0 +1,191 @@
+package repository_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+func TestUpdateRollback(t *testing.T) {
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	r := newRepository(t)
+	var todoIDs []string
+	for _, title := range []string{"Feed the cat", "Water plants", "Buy milk"} {
+		id, err := r.Add(title, false, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		todoIDs = append(todoIDs, id)
+	}
+	if err := r.UpdateUndoable("Mark done", func(tx *repository.Tx) error {
+		_, err := tx.SetDone(todoIDs[2:], true)
+		return err
+	}); err != nil {
+		t.Fatal(err)
+	}
+	before := snapshot(t, r)
+
+	var events atomic.Int64
+	defer r.Subscribe(func(repository.Event) { events.Add(1) })()
+
+	errFail := errors.New("fail")
+	err := r.Update(func(tx *repository.Tx) error {
+		if _, err := tx.Add("Walk the dog", false, now); err != nil {
+			return err
+		}
+		if _, err := tx.Rename(todoIDs[0], "Feed the fish"); err != nil {
+			return err
+		}
+		if _, err := tx.Move(todoIDs[1], "", todoIDs[0]); err != nil {
+			return err
+		}
+		if _, err := tx.Archive(todoIDs[2:]); err != nil {
+			return err
+		}
+		if err := tx.Remove(todoIDs[1]); err != nil {
+			return err
+		}
+		if err := tx.SetListLanguage("", repository.LanguageGerman); err != nil {
+			return err
+		}
+		if _, _, err := tx.Undo(); err != nil {
+			return err
+		}
+		return errFail
+	})
+	if !errors.Is(err, errFail) {
+		t.Fatalf("expected %v, got %v", errFail, err)
+	}
+
+	if after := snapshot(t, r); !reflect.DeepEqual(before, after) {
+		t.Errorf("todos changed\nbefore: %v\nafter:  %v", before, after)
+	}
+	if n := r.Len(); n != len(before) {
+		t.Errorf("expected %d todos, got %d", len(before), n)
+	}
+	if n := events.Load(); n != 0 {
+		t.Errorf("expected no events, got %d", n)
+	}
+	if l := r.ListLanguages()[""]; l != repository.LanguageNone {
+		t.Errorf("expected no language, got %q", l)
+	}
+	if a := r.UndoAction(); a != "Mark done" {
+		t.Errorf("expected undo action %q, got %q", "Mark done", a)
+	}
+	for term, expect := range map[string][]string{
+		"cat":  {todoIDs[0]},
+		"fish": nil,
+		"dog":  nil,
+		"milk": {todoIDs[2]},
+	} {
+		found, err := r.Find(context.Background(), term)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ids(found); !slices.Equal(got, expect) {
+			t.Errorf("find %q: expected %v, got %v", term, expect, got)
+		}
+	}
+
+	// IDs of rolled back todos are reused.
+	id, err := r.Add("Walk the dog", false, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	found, err := r.Find(context.Background(), "dog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 1 || found[0].ID != id {
+		t.Errorf("find %q: expected %s, got %v", "dog", id, found)
+	}
+}
+
+func TestUpdateConcurrent(t *testing.T) {
+	const writers, readers, updates = 4, 4, 50
+	now := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	r := newRepository(t)
+
+	var wg sync.WaitGroup
+	var added atomic.Int64
+	errFail := errors.New("fail")
+	for w := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range updates {
+				err := r.Update(func(tx *repository.Tx) error {
+					title := fmt.Sprintf("Todo %d %d", w, i)
+					id, err := tx.Add(title, false, now)
+					if err != nil {
+						return err
+					}
+					if _, err := tx.Add(title+" done", true, now); err != nil {
+						return err
+					}
+					if i%2 == 1 {
+						// Either both todos are added or none.
+						return errFail
+					}
+					_, err = tx.Toggle(id)
+					return err
+				})
+				switch {
+				case err == nil:
+					added.Add(2)
+				case !errors.Is(err, errFail):
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	for range readers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range updates {
+				err := r.View(func(tx *repository.Tx) error {
+					all, err := tx.All()
+					if err != nil {
+						return err
+					}
+					done, total := tx.Progress()
+					if total != tx.Len() || total != len(all) {
+						return fmt.Errorf("total %d, len %d, all %d",
+							total, tx.Len(), len(all))
+					}
+					// Todos are added in done pairs.
+					if total%2 != 0 || done != total {
+						return fmt.Errorf("%d of %d done", done, total)
+					}
+					return nil
+				})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := r.Len(); int64(n) != added.Load() {
+		t.Errorf("expected %d todos, got %d", added.Load(), n)
+	}
+	found, err := r.Find(context.Background(), "todo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int64(len(found)) != added.Load() {
+		t.Errorf("expected %d search results, got %d", added.Load(), len(found))
+	}
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blevesearch/bleve/v2/analysis"
+)
+
+// Ukrainian text analysis. There's no Ukrainian analyzer in bleve,
+// so stop words, normalization and a light stemmer are provided here.
+
+// ukrainianStopWords are common words not worth indexing.
+var ukrainianStopWords = strings.Fields(`
+	а або аж але б би бо був була були було бути в вам вас вже ви від він
+	вона вони воно все всі втім г де для до дуже є ж же з за зі і із й
+	його її їй їм їх коли ми мене мені мій мої моя на над нам нас не неї
+	ні ній ним них ну о об от по при про під після саме та так також там
+	те теж ти тим тих то тоді того тому ту тут у уже хто це цей ці цього
+	цю ця чи чого що щоб ще я як яка який які якщо
+`)
+
+// ukrainianSuffixes are inflection suffixes of nouns, adjectives and verbs
+// removed by the stemmer, sorted longest first by init.
+var ukrainianSuffixes = strings.Fields(`
+	ього ьому ого ому ими іми ій ий им ім их іх ої ою ую юю ая яя
+	ами ями ові еві єві ах ях ам ям ом ем єм ею єю ів їв ей
+	ити ати яти іти уть ють ать ять ить іть емо ємо имо ете єте ите
+	ала ила ола ало ило али или ав ив ла ли ло ти еш єш иш ть
+	а я и і ї у ю о е є ь
+`)
+
+// ukrainianReflexiveSuffixes are removed before the inflection suffixes.
+var ukrainianReflexiveSuffixes = []string{"ся", "сь"}
+
+// ukrainianMinStem is the minimum number of runes left by the stemmer.
+const ukrainianMinStem = 2
+
+func init() {
+	slices.SortStableFunc(ukrainianSuffixes, func(a, b string) int {
+		return utf8.RuneCountInString(b) - utf8.RuneCountInString(a)
+	})
+}
+
+// ukrainianNormalizer removes apostrophes, which are written in many ways
+// (м'ясо, м’ясо, мʼясо), and replaces the rarely typed ґ with г.
+var ukrainianNormalizer = strings.NewReplacer(
+	"'", "", "’", "", "ʼ", "", "`", "",
+	"ґ", "г",
+)
+
+type ukrainianNormalizeFilter struct{}
+
+func (ukrainianNormalizeFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, t := range input {
+		t.Term = []byte(ukrainianNormalizer.Replace(string(t.Term)))
+	}
+	return input
+}
+
+type ukrainianStemmerFilter struct{}
+
+func (ukrainianStemmerFilter) Filter(input analysis.TokenStream) analysis.TokenStream {
+	for _, t := range input {
+		if !t.KeyWord {
+			t.Term = []byte(stemUkrainian(string(t.Term)))
+		}
+	}
+	return input
+}
+
+// stemUkrainian removes the reflexive and the longest inflection suffix
+// of the lower case word.
+func stemUkrainian(word string) string {
+	word = trimUkrainianSuffix(word, ukrainianReflexiveSuffixes)
+	return trimUkrainianSuffix(word, ukrainianSuffixes)
+}
+
+func trimUkrainianSuffix(word string, suffixes []string) string {
+	n := utf8.RuneCountInString(word)
+	for _, s := range suffixes {
+		if strings.HasSuffix(word, s) &&
+			n-utf8.RuneCountInString(s) >= ukrainianMinStem {
+			return word[:len(word)-len(s)]
+		}
+	}
+	return word
+}
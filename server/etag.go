@@ -0,0 +1,44 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/romshark/htmx-demo-todoapp/caldav"
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// etag returns the entity tag of the state of t, which is its version.
+// It's the same as the one of CalDAV.
+func etag(t repository.Todo) string { return caldav.ETag(t) }
+
+// ifMatchVersion returns the todo version the If-Match header of r requires.
+// Returns ok=false if there is no If-Match header or it matches any version.
+func ifMatchVersion(r *http.Request) (version uint64, ok bool, err error) {
+	m := strings.TrimSpace(r.Header.Get("If-Match"))
+	if m == "" || m == "*" {
+		return 0, false, nil
+	}
+	m = strings.TrimPrefix(m, "W/")
+	if len(m) < 2 || m[0] != '"' || m[len(m)-1] != '"' {
+		return 0, false, errors.New("malformed entity tag")
+	}
+	version, err = strconv.ParseUint(m[1:len(m)-1], 10, 64)
+	if err != nil {
+		return 0, false, errors.New("unknown entity tag")
+	}
+	return version, true, nil
+}
+
+// hxHeadersIfMatch returns the hx-headers attribute value
+// making a change of t conditional on its current version.
+func hxHeadersIfMatch(t repository.Todo) string {
+	b, err := json.Marshal(map[string]string{"If-Match": etag(t)})
+	if err != nil {
+		panic(err)
+	}
+	return string(b)
+}
@@ -0,0 +1,31 @@
+package server
+
+import "testing"
+
+func TestFacetTerm(t *testing.T) {
+	for _, tt := range []struct {
+		searchTerm, query string
+		term              string
+		active            bool
+	}{
+		{"", "done:true", "done:true", false},
+		{"cat", "done:true", "cat done:true", false},
+		{"cat done:true", "done:true", "cat", true},
+		{
+			"cat", "created:>=2026-10-13 created:<2026-10-19",
+			"cat created:>=2026-10-13 created:<2026-10-19", false,
+		},
+		{"created:>=2026-10-13 cat created:<2026-10-19", "created:>=2026-10-13 created:<2026-10-19", "cat", true},
+		// Only a part of the qualifiers.
+		{
+			"cat created:>=2026-10-13", "created:>=2026-10-13 created:<2026-10-19",
+			"cat created:>=2026-10-13 created:<2026-10-19", false,
+		},
+	} {
+		term, active := facetTerm(tt.searchTerm, tt.query)
+		if term != tt.term || active != tt.active {
+			t.Errorf("facetTerm(%q, %q) = %q, %t, want %q, %t",
+				tt.searchTerm, tt.query, term, active, tt.term, tt.active)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package server_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHighlightEscaped(t *testing.T) {
+	srv, _ := newServer(t, `Feed the <b>cat</b> & "dog"`, "<script>alert(1)</script> cat")
+	code, body := get(t, newClient(t, srv), srv, "/?term=cat")
+	if code != http.StatusOK {
+		t.Fatalf("status %d", code)
+	}
+	for _, want := range []string{
+		"Feed the &lt;b&gt;<mark>cat</mark>&lt;/b&gt; &amp; &#34;dog&#34;",
+		"&lt;script&gt;alert(1)&lt;/script&gt; <mark>cat</mark>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %s", want)
+		}
+	}
+	for _, markup := range []string{"<b>", "<script>"} {
+		if strings.Contains(body, markup) {
+			t.Errorf("title markup %s passed through", markup)
+		}
+	}
+}
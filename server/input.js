@@ -1,5 +1,5 @@
-// Tell eslint that Alpine is a global
-/* global Alpine */
+// Tell eslint that Alpine, htmx and Sortable are globals
+/* global Alpine, htmx, Sortable */
 
 document.addEventListener("alpine:init", () => {
   Alpine.data("pageIndex", () => ({
@@ -34,6 +34,90 @@ document.addEventListener("alpine:init", () => {
         document.removeEventListener("keydown", h);
       };
     },
+
+    // Search autocomplete (ARIA combobox with a listbox popup).
+    suggestionsOpen: false,
+    activeSuggestion: -1,
+
+    suggestionOptions() {
+      return Array.from(
+        this.$refs.suggestions.querySelectorAll("[role=option]"),
+      );
+    },
+
+    activeSuggestionID() {
+      const o = this.suggestionOptions()[this.activeSuggestion];
+      return o ? o.id : null;
+    },
+
+    suggest() {
+      const prefix = this.$refs.inputSearch.value;
+      htmx
+        .ajax("GET", `/suggest?prefix=${encodeURIComponent(prefix)}`, {
+          target: "#suggestions",
+          swap: "innerHTML",
+        })
+        .then(() => {
+          this.activeSuggestion = -1;
+          this.suggestionsOpen =
+            document.activeElement === this.$refs.inputSearch &&
+            this.suggestionOptions().length > 0;
+        });
+    },
+
+    closeSuggestions() {
+      this.suggestionsOpen = false;
+      this.setActiveSuggestion(-1);
+    },
+
+    setActiveSuggestion(i) {
+      this.activeSuggestion = i;
+      this.suggestionOptions().forEach((o, j) => {
+        o.setAttribute("aria-selected", i === j ? "true" : "false");
+      });
+    },
+
+    chooseSuggestion(option) {
+      this.$refs.inputSearch.value = option.dataset.term;
+      this.closeSuggestions();
+      htmx.trigger(this.$refs.formSearch, "input");
+    },
+
+    onSuggestionKey(e) {
+      const options = this.suggestionOptions();
+      switch (e.key) {
+        case "ArrowDown":
+        case "ArrowUp": {
+          if (options.length < 1) {
+            return;
+          }
+          e.preventDefault();
+          this.suggestionsOpen = true;
+          const n = options.length;
+          let i = (this.activeSuggestion + 1) % n;
+          if (e.key === "ArrowUp") {
+            i = this.activeSuggestion <= 0 ? n - 1 : this.activeSuggestion - 1;
+          }
+          this.setActiveSuggestion(i);
+          options[i].scrollIntoView({ block: "nearest" });
+          break;
+        }
+        case "Enter": {
+          if (this.suggestionsOpen && options[this.activeSuggestion]) {
+            e.preventDefault();
+            this.chooseSuggestion(options[this.activeSuggestion]);
+          }
+          break;
+        }
+        case "Escape": {
+          if (this.suggestionsOpen) {
+            e.preventDefault();
+            this.closeSuggestions();
+          }
+          break;
+        }
+      }
+    },
   }));
 });
 
@@ -62,3 +146,69 @@ document.addEventListener("htmx:afterRequest", function (event) {
   // Remove the class (just in case it was applied)
   target.classList.remove("non-interactable");
 });
+
+// Searches are numbered per page load so that the server can drop
+// superseded searches (see searchSequence) and responses to older
+// searches arriving after newer ones were sent aren't swapped in.
+const searchClient = Math.random().toString(36).slice(2);
+let searchSeq = 0;
+
+function isSearch(detail) {
+  return detail.verb === "get" && detail.path.split("?")[0] === "/";
+}
+
+document.addEventListener("htmx:configRequest", function (event) {
+  if (isSearch(event.detail)) {
+    searchSeq++;
+    event.detail.headers["X-Search-Client"] = searchClient;
+    event.detail.headers["X-Search-Seq"] = String(searchSeq);
+  }
+});
+
+document.addEventListener("htmx:beforeSwap", function (event) {
+  const seq = event.detail.requestConfig?.headers["X-Search-Seq"];
+  if (seq && Number(seq) < searchSeq) {
+    event.detail.shouldSwap = false;
+  }
+});
+
+// A rejected change of a todo that was changed in the meantime
+// responds with a fragment letting the user reload or overwrite.
+document.addEventListener("htmx:beforeSwap", function (event) {
+  if (event.detail.xhr.status === 412) {
+    event.detail.shouldSwap = true;
+    event.detail.isError = false;
+  }
+});
+
+// Reorder todos by drag and drop (see https://htmx.org/examples/sortable/).
+// The moved todo is placed between its new neighbours.
+htmx.onLoad(function (content) {
+  content.querySelectorAll(".sortable").forEach(function (list) {
+    const sortable = new Sortable(list, {
+      animation: 150,
+      handle: ".drag-handle",
+      // Excludes the infinite scrolling sentinel.
+      draggable: "li[data-id]",
+      onEnd: function (event) {
+        if (event.oldIndex === event.newIndex) {
+          return;
+        }
+        const item = event.item;
+        const prev = item.previousElementSibling;
+        const next = item.nextElementSibling;
+        sortable.option("disabled", true);
+        htmx
+          .ajax("POST", `/${item.dataset.id}/move/`, {
+            target: "#list",
+            swap: "outerHTML",
+            values: {
+              after: prev?.dataset.id ?? "",
+              before: next?.dataset.id ?? "",
+            },
+          })
+          .finally(() => sortable.option("disabled", false));
+      },
+    });
+  });
+});
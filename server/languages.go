@@ -0,0 +1,40 @@
+package server
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+func (s *Server) handleGetLanguages(w http.ResponseWriter, r *http.Request) {
+	headersNoCache(w)
+	render(w, r, pageLanguages(
+		s.repo.DefaultLanguage(), s.repo.Lists(), s.repo.ListLanguages(),
+	), "pageLanguages")
+}
+
+// handlePostLanguages sets the language of "list",
+// or the default language if no list is given.
+func (s *Server) handlePostLanguages(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	lang := repository.Language(r.PostForm.Get("language"))
+	if !lang.Valid() {
+		http.Error(w, "unsupported language", http.StatusBadRequest)
+		return
+	}
+	var err error
+	if r.PostForm.Has("list") {
+		err = s.repo.SetListLanguage(r.PostForm.Get("list"), lang)
+	} else {
+		err = s.repo.SetDefaultLanguage(lang)
+	}
+	if err != nil {
+		internalErr(w, err, "setting language", slog.Default())
+		return
+	}
+	http.Redirect(w, r, "/languages/", http.StatusSeeOther)
+}
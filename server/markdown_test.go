@@ -0,0 +1,51 @@
+package server_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestMarkdownOrder(t *testing.T) {
+	const document = `## todos
+
+- [ ] Buy milk
+- [x] Clean up
+  - [x] Kitchen
+  - [ ] Bathroom
+- [ ] Call mom
+`
+	srv, repo := newServer(t)
+	c := newClient(t, srv)
+	resp, err := c.Post(srv.URL+"/import.md", "text/markdown", strings.NewReader(document))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("import: status %d", resp.StatusCode)
+	}
+
+	// The list is in document order.
+	all, err := repo.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var titles []string
+	for _, td := range all {
+		titles = append(titles, td.Title)
+	}
+	want := "Buy milk, Clean up, Kitchen, Bathroom, Call mom"
+	if got := strings.Join(titles, ", "); got != want {
+		t.Errorf("order %s, want %s", got, want)
+	}
+
+	// The export is in list order and equals the imported document.
+	code, body := get(t, c, srv, "/export.md")
+	if code != http.StatusOK {
+		t.Fatalf("export: status %d", code)
+	}
+	if body != document {
+		t.Errorf("export:\n%s\nwant:\n%s", body, document)
+	}
+}
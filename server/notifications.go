@@ -0,0 +1,138 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/romshark/htmx-demo-todoapp/reminder"
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// notifications broadcasts reminders and todo changes to all browsers
+// connected to the server-sent events endpoint.
+type notifications struct {
+	lock    sync.Mutex
+	clients map[*client]struct{}
+}
+
+var _ reminder.Notifier = new(notifications)
+
+// client is a browser connected to the server-sent events endpoint.
+type client struct {
+	reminders chan reminder.Notification
+	// changed coalesces todo changes until the client is notified.
+	changed chan struct{}
+}
+
+// Notify sends n to all connected clients.
+// Clients that aren't keeping up miss the notification.
+func (b *notifications) Notify(_ context.Context, n reminder.Notification) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for c := range b.clients {
+		select {
+		case c.reminders <- n:
+		default:
+			slog.Warn("dropping notification for slow client",
+				slog.String("todo", n.TodoID))
+		}
+	}
+	return nil
+}
+
+// todosChanged notifies all connected clients that todos changed.
+// It's registered as a repository event listener in New.
+func (b *notifications) todosChanged(repository.Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for c := range b.clients {
+		select {
+		case c.changed <- struct{}{}:
+		default: // Already notified.
+		}
+	}
+}
+
+func (b *notifications) subscribe() (c *client, unsubscribe func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.clients == nil {
+		b.clients = map[*client]struct{}{}
+	}
+	c = &client{
+		reminders: make(chan reminder.Notification, 8),
+		changed:   make(chan struct{}, 1),
+	}
+	b.clients[c] = struct{}{}
+	return c, func() {
+		b.lock.Lock()
+		defer b.lock.Unlock()
+		delete(b.clients, c)
+	}
+}
+
+// Notifier returns the notifier delivering reminders to connected browsers.
+func (s *Server) Notifier() reminder.Notifier { return &s.notifications }
+
+// handleGetNotifications streams reminders as server-sent events
+// named "reminder" containing the rendered notification fragment,
+// and todo changes as events named "todos-changed" without data.
+func (s *Server) handleGetNotifications(w http.ResponseWriter, r *http.Request) {
+	c, unsubscribe := s.notifications.subscribe()
+	defer unsubscribe()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	headersNoCache(w)
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		slog.Error("flushing event stream", slog.Any("err", err))
+		return
+	}
+
+	var buf bytes.Buffer
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.changed:
+			if err := writeEvent(w, "todos-changed", ""); err != nil {
+				return // The client disconnected.
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		case n := <-c.reminders:
+			buf.Reset()
+			if err := partNotification(n).Render(r.Context(), &buf); err != nil {
+				slog.Error("rendering template",
+					slog.Any("err", err),
+					slog.String("name", "partNotification"))
+				continue
+			}
+			if err := writeEvent(w, "reminder", buf.String()); err != nil {
+				return // The client disconnected.
+			}
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// writeEvent writes a server-sent event, data may contain line breaks.
+func writeEvent(w http.ResponseWriter, event, data string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "event: %s\n", event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(&b, "data: %s\n", line)
+	}
+	b.WriteString("\n")
+	_, err := w.Write([]byte(b.String()))
+	return err
+}
@@ -1 +1 @@
-(()=>{document.addEventListener("alpine:init",()=>{Alpine.data("pageIndex",()=>({init(){this.$refs.formSearch.action="javascript:void(0)";let t=document.addEventListener("keydown",e=>{if(!(["INPUT","TEXTAREA"].includes(document.activeElement.tagName)&&document.activeElement.type==="text"))switch(e.key){case"n":{this.$refs.inputAddNew&&(this.$refs.inputAddNew.focus(),e.preventDefault());break}case"f":{this.$refs.inputSearch&&(this.$refs.inputSearch.focus(),e.preventDefault());break}}});this.$destroy=()=>{document.removeEventListener("keydown",t)}}}))});var n=150;document.addEventListener("htmx:beforeRequest",function(t){var e=t.detail.target;e.htmxTimeoutId=setTimeout(function(){e.classList.add("non-interactable")},n)});document.addEventListener("htmx:afterRequest",function(t){var e=t.detail.target;e.htmxTimeoutId&&(clearTimeout(e.htmxTimeoutId),e.htmxTimeoutId=null),e.classList.remove("non-interactable")});})();
+(()=>{document.addEventListener("alpine:init",()=>{Alpine.data("pageIndex",()=>({init(){this.$refs.formSearch.action="javascript:void(0)";let t=document.addEventListener("keydown",e=>{if(!(["INPUT","TEXTAREA"].includes(document.activeElement.tagName)&&document.activeElement.type==="text"))switch(e.key){case"n":{this.$refs.inputAddNew&&(this.$refs.inputAddNew.focus(),e.preventDefault());break}case"f":{this.$refs.inputSearch&&(this.$refs.inputSearch.focus(),e.preventDefault());break}}});this.$destroy=()=>{document.removeEventListener("keydown",t)}},suggestionsOpen:!1,activeSuggestion:-1,suggestionOptions(){return Array.from(this.$refs.suggestions.querySelectorAll("[role=option]"))},activeSuggestionID(){let t=this.suggestionOptions()[this.activeSuggestion];return t?t.id:null},suggest(){let t=this.$refs.inputSearch.value;htmx.ajax("GET",`/suggest?prefix=${encodeURIComponent(t)}`,{target:"#suggestions",swap:"innerHTML"}).then(()=>{this.activeSuggestion=-1,this.suggestionsOpen=document.activeElement===this.$refs.inputSearch&&this.suggestionOptions().length>0})},closeSuggestions(){this.suggestionsOpen=!1,this.setActiveSuggestion(-1)},setActiveSuggestion(t){this.activeSuggestion=t,this.suggestionOptions().forEach((e,o)=>{e.setAttribute("aria-selected",t===o?"true":"false")})},chooseSuggestion(t){this.$refs.inputSearch.value=t.dataset.term,this.closeSuggestions(),htmx.trigger(this.$refs.formSearch,"input")},onSuggestionKey(t){let e=this.suggestionOptions();switch(t.key){case"ArrowDown":case"ArrowUp":{if(e.length<1)return;t.preventDefault(),this.suggestionsOpen=!0;let o=e.length,i=(this.activeSuggestion+1)%o;t.key==="ArrowUp"&&(i=this.activeSuggestion<=0?o-1:this.activeSuggestion-1),this.setActiveSuggestion(i),e[i].scrollIntoView({block:"nearest"});break}case"Enter":{this.suggestionsOpen&&e[this.activeSuggestion]&&(t.preventDefault(),this.chooseSuggestion(e[this.activeSuggestion]));break}case"Escape":{this.suggestionsOpen&&(t.preventDefault(),this.closeSuggestions());break}}}}))});var n=150;document.addEventListener("htmx:beforeRequest",function(t){var e=t.detail.target;e.htmxTimeoutId=setTimeout(function(){e.classList.add("non-interactable")},n)});document.addEventListener("htmx:afterRequest",function(t){var e=t.detail.target;e.htmxTimeoutId&&(clearTimeout(e.htmxTimeoutId),e.htmxTimeoutId=null),e.classList.remove("non-interactable")});var c=Math.random().toString(36).slice(2),r=0;function u(t){return t.verb==="get"&&t.path.split("?")[0]==="/"}document.addEventListener("htmx:configRequest",function(t){u(t.detail)&&(r++,t.detail.headers["X-Search-Client"]=c,t.detail.headers["X-Search-Seq"]=String(r))});document.addEventListener("htmx:beforeSwap",function(t){let e=t.detail.requestConfig?.headers["X-Search-Seq"];e&&Number(e)<r&&(t.detail.shouldSwap=!1)});document.addEventListener("htmx:beforeSwap",function(t){t.detail.xhr.status===412&&(t.detail.shouldSwap=!0,t.detail.isError=!1)});htmx.onLoad(function(t){t.querySelectorAll(".sortable").forEach(function(e){let o=new Sortable(e,{animation:150,handle:".drag-handle",draggable:"li[data-id]",onEnd:function(i){if(i.oldIndex===i.newIndex)return;let a=i.item,d=a.previousElementSibling,s=a.nextElementSibling;o.option("disabled",!0),htmx.ajax("POST",`/${a.dataset.id}/move/`,{target:"#list",swap:"outerHTML",values:{after:d?.dataset.id??"",before:s?.dataset.id??""}}).finally(()=>o.option("disabled",!1))}})})});})();
@@ -0,0 +1,93 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Headers numbering the search requests of a page load
+// so that superseded searches can be dropped (see searchSequence).
+const (
+	HeaderSearchClient = "X-Search-Client"
+	HeaderSearchSeq    = "X-Search-Seq"
+)
+
+// MaxSearchClients is the number of clients whose last search is tracked.
+const MaxSearchClients = 1000
+
+// searchSequence cancels the search of a client when it begins a newer one
+// and rejects searches received after a newer one, which happens when
+// requests are delayed by varying amounts (see httpsim.yml).
+// Clients are page loads identified by HeaderSearchClient
+// numbering their searches in HeaderSearchSeq.
+type searchSequence struct {
+	lock    sync.Mutex
+	clients map[string]*searchClient
+}
+
+type searchClient struct {
+	seq uint64
+	// cancel cancels the running search, nil if there is none.
+	cancel   context.CancelFunc
+	lastSeen time.Time
+}
+
+// begin returns the context of the search request r, which is canceled
+// when the client begins a newer search, and done to be called when
+// the search is done. Returns ok=false if a newer search already began.
+// Requests without the headers are never superseded.
+func (q *searchSequence) begin(r *http.Request) (
+	ctx context.Context, done func(), ok bool,
+) {
+	id := r.Header.Get(HeaderSearchClient)
+	seq, err := strconv.ParseUint(r.Header.Get(HeaderSearchSeq), 10, 64)
+	if id == "" || err != nil {
+		return r.Context(), func() {}, true
+	}
+
+	q.lock.Lock()
+	defer q.lock.Unlock()
+	c, found := q.clients[id]
+	if found && seq <= c.seq {
+		return nil, nil, false
+	}
+	if !found {
+		if q.clients == nil {
+			q.clients = map[string]*searchClient{}
+		}
+		if len(q.clients) >= MaxSearchClients {
+			q.evict()
+		}
+		c = &searchClient{}
+		q.clients[id] = c
+	}
+	if c.cancel != nil {
+		c.cancel()
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	c.seq, c.cancel, c.lastSeen = seq, cancel, time.Now()
+	return ctx, func() {
+		cancel()
+		q.lock.Lock()
+		defer q.lock.Unlock()
+		if c.seq == seq {
+			c.cancel = nil
+		}
+	}, true
+}
+
+// evict forgets the least recently seen client.
+// Must be called with q.lock held.
+func (q *searchSequence) evict() {
+	var oldestID string
+	var oldest *searchClient
+	for id, c := range q.clients {
+		if oldest == nil || c.lastSeen.Before(oldest.lastSeen) {
+			oldestID, oldest = id, c
+		}
+	}
+	delete(q.clients, oldestID)
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/reminder"
+	"github.com/romshark/htmx-demo-todoapp/repository"
+	"github.com/romshark/htmx-demo-todoapp/views"
+	"github.com/romshark/htmx-demo-todoapp/webhook"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	repo, err := repository.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+	if _, err := repo.Add("Feed the cat", false, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	hooks, err := webhook.Open("", reminder.RealClock{}, &http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := views.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return New(repo, hooks, v)
+}
+
+// searchRequest returns a search request numbered seq by client.
+func searchRequest(client string, seq uint64) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/?term=cat", nil)
+	r.Header.Set("HX-Request", "true")
+	if client != "" {
+		r.Header.Set(HeaderSearchClient, client)
+		r.Header.Set(HeaderSearchSeq, strconv.FormatUint(seq, 10))
+	}
+	return r
+}
+
+func serve(s *Server, r *http.Request) int {
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w.Code
+}
+
+func TestSearchSuperseded(t *testing.T) {
+	s := newTestServer(t)
+
+	// A slow search of client a is still running.
+	running, done, ok := s.searches.begin(searchRequest("a", 1))
+	if !ok {
+		t.Fatal("first search rejected")
+	}
+	defer done()
+
+	if code := serve(s, searchRequest("a", 2)); code != http.StatusOK {
+		t.Fatalf("newer search: status %d", code)
+	}
+	select {
+	case <-running.Done():
+	default:
+		t.Fatal("superseded search wasn't canceled")
+	}
+
+	// Searches arriving after a newer one are dropped.
+	for _, seq := range []uint64{1, 2} {
+		if code := serve(s, searchRequest("a", seq)); code != http.StatusNoContent {
+			t.Errorf("search %d after 2: status %d, want 204", seq, code)
+		}
+	}
+	if code := serve(s, searchRequest("a", 3)); code != http.StatusOK {
+		t.Errorf("search 3: status %d", code)
+	}
+	// Clients are independent and requests without headers never superseded.
+	if code := serve(s, searchRequest("b", 1)); code != http.StatusOK {
+		t.Errorf("other client: status %d", code)
+	}
+	if code := serve(s, searchRequest("", 0)); code != http.StatusOK {
+		t.Errorf("no headers: status %d", code)
+	}
+}
+
+func TestSearchCanceled(t *testing.T) {
+	s := newTestServer(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := searchRequest("a", 1).WithContext(ctx)
+	if code := serve(s, r); code != http.StatusNoContent {
+		t.Fatalf("canceled search: status %d, want 204", code)
+	}
+}
+
+func TestSearchSequenceOverlapping(t *testing.T) {
+	s := newTestServer(t)
+	// Concurrent searches of one client arriving in random order
+	// either succeed or are superseded.
+	const n = 20
+	codes := make(chan int, n)
+	for seq := range uint64(n) {
+		go func() { codes <- serve(s, searchRequest("a", seq+1)) }()
+	}
+	ok := 0
+	for range n {
+		switch code := <-codes; code {
+		case http.StatusOK:
+			ok++
+		case http.StatusNoContent:
+		default:
+			t.Errorf("status %d", code)
+		}
+	}
+	if ok < 1 {
+		t.Error("no search succeeded")
+	}
+	if code := serve(s, searchRequest("a", n)); code != http.StatusNoContent {
+		t.Errorf("repeated newest search: status %d, want 204", code)
+	}
+}
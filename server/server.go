@@ -1,17 +1,33 @@
 package server
 
 import (
+	"bytes"
+	"context"
 	"embed"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/a-h/templ"
 	"github.com/romshark/httpsim"
 
+	"github.com/romshark/htmx-demo-todoapp/caldav"
+	"github.com/romshark/htmx-demo-todoapp/ical"
+	"github.com/romshark/htmx-demo-todoapp/markdown"
+	"github.com/romshark/htmx-demo-todoapp/quickadd"
 	"github.com/romshark/htmx-demo-todoapp/repository"
+	"github.com/romshark/htmx-demo-todoapp/todotxt"
+	"github.com/romshark/htmx-demo-todoapp/views"
+	"github.com/romshark/htmx-demo-todoapp/webhook"
 )
 
 // embedDirPublic Embeds the public assets directory
@@ -50,14 +66,22 @@ func WithLog(s *Server) http.Handler {
 }
 
 type Server struct {
-	mux  *http.ServeMux
-	repo *repository.Repository
+	mux           *http.ServeMux
+	repo          *repository.Repository
+	hooks         *webhook.Dispatcher
+	views         *views.Store
+	notifications notifications
+	queries       recentQueries
+	searches      searchSequence
 }
 
 var _ http.Handler = new(Server)
 
-func New(repo *repository.Repository) *Server {
-	s := &Server{repo: repo}
+func New(
+	repo *repository.Repository, hooks *webhook.Dispatcher, views *views.Store,
+) *Server {
+	s := &Server{repo: repo, hooks: hooks, views: views}
+	repo.Subscribe(s.notifications.todosChanged)
 	m := http.NewServeMux()
 
 	m.Handle("GET /public/", http.FileServer(http.FS(embedDirPublic)))
@@ -65,6 +89,54 @@ func New(repo *repository.Repository) *Server {
 	// The following endpoints render navigable pages.
 	m.HandleFunc("GET /{$}", s.handleIndex)
 
+	// Infinite scrolling loads the following pages of the list.
+	m.HandleFunc("GET /page/{$}", s.handleGetPage)
+
+	// Autocomplete options of the search input.
+	m.HandleFunc("GET /suggest", s.handleGetSuggest)
+
+	// Reloads the list, e.g. after a conflicting change.
+	m.HandleFunc("GET /list/{$}", s.handleGetList)
+
+	// Saved searches are rendered as virtual lists.
+	m.HandleFunc("GET /views/{slug}/{$}", s.handleGetView)
+	m.HandleFunc("POST /views/{$}", s.handlePostViews)
+	m.HandleFunc("POST /views/{slug}/delete/{$}", s.handlePostViewDelete)
+
+	// Webhook registration and delivery log.
+	m.HandleFunc("GET /webhooks/{$}", s.handleGetWebhooks)
+	m.HandleFunc("POST /webhooks/{$}", s.handlePostWebhooks)
+	m.HandleFunc("POST /webhooks/{id}/delete/{$}", s.handlePostWebhookDelete)
+	m.HandleFunc("GET /languages/{$}", s.handleGetLanguages)
+	m.HandleFunc("POST /languages/{$}", s.handlePostLanguages)
+
+	// Reminders and todo changes are pushed to the browser as server-sent events.
+	m.HandleFunc("GET /notifications/{$}", s.handleGetNotifications)
+
+	// The following endpoints export and import todos in other formats.
+	m.HandleFunc("GET /todo.txt", s.handleGetTodoTXT)
+	m.HandleFunc("POST /todo.txt", s.handlePostTodoTXT)
+	m.HandleFunc("GET /export.md", s.handleGetExportMD)
+	m.HandleFunc("POST /import.md", s.handlePostImportMD)
+	m.HandleFunc("GET /lists/{list}/todos.ics", s.handleGetListICS)
+	m.HandleFunc("POST /lists/{list}/todos.ics", s.handlePostListICS)
+
+	// The backup endpoint is only available to loopback clients
+	// and is used by the "backup" command while the server is running.
+	m.HandleFunc("GET /backup/{$}", s.handleGetBackup)
+
+	// CalDAV for two-way synchronization with task apps.
+	// Methods are registered explicitly to not conflict with "POST /{id}/...".
+	dav := caldav.NewHandler(repo, "/caldav/")
+	for _, method := range []string{
+		"OPTIONS", "PROPFIND", "REPORT", "GET", "PUT", "DELETE",
+	} {
+		m.Handle(method+" /caldav/", dav)
+	}
+	m.Handle("/.well-known/caldav", http.RedirectHandler(
+		"/caldav/", http.StatusMovedPermanently,
+	))
+
 	// The following endpoints render HTMX components for partial reloads of frames.
 	// Non-HTMX requests are rejected with 400 Bad Request.
 
@@ -75,6 +147,31 @@ func New(repo *repository.Repository) *Server {
 		s.handlePostToggleTodo)
 	m.HandleFunc("POST /{$}",
 		s.handlePostIndex)
+	m.HandleFunc("GET /quick-add/{$}",
+		s.handleGetQuickAddPreview)
+
+	// Archives all done todos matching "term".
+	m.HandleFunc("POST /clear-completed/{$}",
+		s.handlePostClearCompleted)
+	m.HandleFunc("GET /archive/{$}",
+		s.handleGetArchive)
+	m.HandleFunc("POST /{id}/unarchive/{$}",
+		s.handlePostTodoUnarchive)
+
+	// Applies the "action" to all todos whose IDs are given as "id".
+	m.HandleFunc("POST /bulk/{$}",
+		s.handlePostBulk)
+	// Reverts the last bulk action.
+	m.HandleFunc("POST /undo/{$}",
+		s.handlePostUndo)
+
+	// Moves the todo between the todos with the IDs "after" and "before".
+	m.HandleFunc("POST /{id}/move/{$}",
+		s.handlePostTodoMove)
+
+	// An empty "remind" value or "clear=true" removes the reminder.
+	m.HandleFunc("POST /{id}/remind/{$}",
+		s.handlePostTodoRemind)
 
 	// This endpoint doesn't use the DELETE method because
 	// HTML form can't issue a DELETE request.
@@ -92,25 +189,66 @@ func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
 func (s *Server) handleIndex(w http.ResponseWriter, r *http.Request) {
 	searchTerm := r.FormValue("term")
+	sort, ok := sortOrder(r)
+	if !ok {
+		http.Error(w, "unsupported sort order", http.StatusBadRequest)
+		return
+	}
 
-	list, err := fetchTodos(s.repo, searchTerm)
-	if err != nil {
-		internalErr(w, err, "getting all todos", slog.Default())
+	ctx, done, ok := s.searches.begin(r)
+	if !ok {
+		// A newer search of the client was received first.
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
+	defer done()
 
-	if searchTerm != "" {
-		headersHXReplaceURL(w, fmt.Sprintf("/?term=%s", url.QueryEscape(searchTerm)))
-	} else {
-		headersHXReplaceURL(w, "/")
+	list, err := fetchTodos(ctx, s.repo, searchTerm, sort, r.FormValue("cursor"))
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	} else if errors.Is(err, context.Canceled) {
+		// Superseded by a newer search or the client is gone.
+		w.WriteHeader(http.StatusNoContent)
+		return
+	} else if err != nil {
+		internalErr(w, err, "getting all todos", slog.Default())
+		return
 	}
+	if searchTerm != "" && list.QueryError == "" && list.Total > 0 {
+		s.queries.add(clientID(w, r), searchTerm)
+	}
+
+	headersHXReplaceURL(w, listURL("/", searchTerm, sort, ""))
 	if isHXRequest(r) {
-		render(w, r, comList(list, searchTerm), "comList")
+		render(w, r, comList(list), "comList")
 		return
 	}
 
 	headersNoCache(w)
-	render(w, r, pageIndex(list, searchTerm), "pageIndex")
+	render(w, r, pageIndex(list, s.repo.Lists(), s.views.All()), "pageIndex")
+}
+
+// handleGetPage renders the list items of the page at "cursor"
+// followed by the sentinel loading the next page when revealed.
+func (s *Server) handleGetPage(w http.ResponseWriter, r *http.Request) {
+	sort, ok := sortOrder(r)
+	if !ok {
+		http.Error(w, "unsupported sort order", http.StatusBadRequest)
+		return
+	}
+	list, err := fetchTodos(
+		r.Context(), s.repo, r.FormValue("term"), sort, r.FormValue("cursor"),
+	)
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		internalErr(w, err, "getting todos", slog.Default())
+		return
+	}
+	headersNoCache(w)
+	render(w, r, partListPage(list), "partListPage")
 }
 
 func (s *Server) handlePostIndex(w http.ResponseWriter, r *http.Request) {
@@ -118,12 +256,13 @@ func (s *Server) handlePostIndex(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	title := r.FormValue("title")
-	if title == "" {
+	now := time.Now()
+	parsed := quickadd.Parse(r.FormValue("title"), now)
+	if parsed.Title == "" {
 		http.Error(w, "title is required", http.StatusBadRequest)
 		return
 	}
-	if _, err := s.repo.Add(title, false, time.Now()); err != nil {
+	if _, err := s.repo.Import([]repository.Todo{parsed.Todo()}, now); err != nil {
 		internalErr(w, err, "addind new todo", slog.Default())
 		return
 	}
@@ -131,6 +270,14 @@ func (s *Server) handlePostIndex(w http.ResponseWriter, r *http.Request) {
 	redirectIndex(w, r)
 }
 
+// handleGetQuickAddPreview renders what the New Todo form would add.
+func (s *Server) handleGetQuickAddPreview(w http.ResponseWriter, r *http.Request) {
+	headersNoCache(w)
+	render(w, r,
+		partQuickAddPreview(quickadd.Parse(r.FormValue("title"), time.Now())),
+		"partQuickAddPreview")
+}
+
 func (s *Server) handlePostTodoDelete(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	if err := s.repo.Remove(id); err != nil {
@@ -146,15 +293,90 @@ func (s *Server) handlePostTodoDelete(w http.ResponseWriter, r *http.Request) {
 	redirectIndex(w, r)
 }
 
+// handlePostToggleTodo marks the todo done or not done as given by "done"
+// or toggles it if "done" is empty, which requires a version. If the
+// If-Match header or the form value "version" doesn't match the version
+// of the todo, which was changed in the meantime, nothing is changed and
+// the todo is replaced with a conflict fragment letting the user reload
+// the list or apply the change anyway.
 func (s *Server) handlePostToggleTodo(w http.ResponseWriter, r *http.Request) {
 	searchTerm := r.FormValue("term")
 	id := r.PathValue("id")
-	_, err := s.repo.Toggle(id)
+	log := slog.With(slog.String("id", id))
+
+	version, conditional, err := ifMatchVersion(r)
 	if err != nil {
-		internalErr(w, err, "toggling todo", slog.With(slog.String("id", id)))
+		http.Error(w, "invalid If-Match header: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Forms submitted without JavaScript can't set If-Match.
+	if v := r.FormValue("version"); !conditional && v != "" {
+		if version, err = strconv.ParseUint(v, 10, 64); err != nil {
+			http.Error(w, "invalid version", http.StatusBadRequest)
+			return
+		}
+		conditional = true
+	}
+	doneValue := r.FormValue("done")
+	done, err := strconv.ParseBool(doneValue)
+	if doneValue != "" && err != nil {
+		http.Error(w, "invalid done", http.StatusBadRequest)
+		return
+	} else if doneValue == "" && !conditional {
+		// Toggling an unknown state could undo someone else's change.
+		http.Error(w, "missing done or version", http.StatusBadRequest)
+		return
+	}
+
+	var t repository.Todo
+	switch {
+	case doneValue == "":
+		err = s.repo.Update(func(tx *repository.Tx) error {
+			if t, err = tx.Get(id); err != nil {
+				return err
+			}
+			done = !t.Done
+			t, err = tx.SetDoneIfVersion(id, done, version)
+			return err
+		})
+	case conditional:
+		t, err = s.repo.SetDoneIfVersion(id, done, version)
+	default:
+		err = s.repo.Update(func(tx *repository.Tx) error {
+			if _, err := tx.SetDone([]string{id}, done); err != nil {
+				return err
+			}
+			t, err = tx.Get(id)
+			return err
+		})
+	}
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "todo not found", http.StatusNotFound)
+		return
+	} else if errors.Is(err, repository.ErrVersionConflict) {
+		log.Info("toggle conflict",
+			slog.Uint64("expected", version), slog.Uint64("actual", t.Version))
+		w.Header().Set("ETag", etag(t))
+		if !isHXRequest(r) {
+			const code = http.StatusPreconditionFailed
+			http.Error(w, "the todo was changed in the meantime", code)
+			return
+		}
+		// Replace the todo instead of the list.
+		w.Header().Set("HX-Retarget", `li[data-id="`+t.ID+`"]`)
+		w.Header().Set("HX-Reswap", "outerHTML")
+		headersNoCache(w)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusPreconditionFailed)
+		render(w, r, partConflict(t, done, searchTerm), "partConflict")
+		return
+	} else if err != nil {
+		internalErr(w, err, "toggling todo", log)
+		return
 	}
-	slog.Info("toggled", slog.String("id", id))
+	log.Info("toggled", slog.Bool("done", t.Done))
 
+	w.Header().Set("ETag", etag(t))
 	if isHXRequest(r) {
 		renderList(w, r, s.repo, searchTerm)
 		return
@@ -163,23 +385,393 @@ func (s *Server) handlePostToggleTodo(w http.ResponseWriter, r *http.Request) {
 	redirectIndex(w, r)
 }
 
+// handleGetList renders the first page of the list
+// within the saved search "view" if given.
+func (s *Server) handleGetList(w http.ResponseWriter, r *http.Request) {
+	headersNoCache(w)
+	renderList(w, r, s.repo, r.FormValue("term"))
+}
+
+func (s *Server) handlePostClearCompleted(w http.ResponseWriter, r *http.Request) {
+	searchTerm := r.FormValue("term")
+	todos, err := fetchAllTodos(r.Context(), s.repo, searchTerm)
+	var qe *repository.QueryError
+	if errors.As(err, &qe) {
+		http.Error(w, qe.Error(), http.StatusBadRequest)
+		return
+	} else if err != nil {
+		internalErr(w, err, "fetching todos", slog.Default())
+		return
+	}
+	var ids []string
+	for _, t := range todos {
+		if t.Done {
+			ids = append(ids, t.ID)
+		}
+	}
+	if _, err := s.repo.Archive(ids); err != nil {
+		internalErr(w, err, "archiving completed todos", slog.Default())
+		return
+	}
+
+	if isHXRequest(r) {
+		renderList(w, r, s.repo, searchTerm)
+		return
+	}
+
+	redirectIndex(w, r)
+}
+
+func (s *Server) handleGetArchive(w http.ResponseWriter, r *http.Request) {
+	searchTerm := r.FormValue("term")
+	var todos []repository.Todo
+	var err error
+	if searchTerm == "" {
+		todos, err = s.repo.Archived()
+	} else {
+		todos, err = s.repo.FindArchived(r.Context(), searchTerm)
+	}
+	queryError := ""
+	var qe *repository.QueryError
+	if errors.As(err, &qe) {
+		queryError = qe.Error()
+	} else if err != nil {
+		internalErr(w, err, "fetching archived todos", slog.Default())
+		return
+	}
+
+	if searchTerm != "" {
+		headersHXReplaceURL(w, "/archive/?term="+url.QueryEscape(searchTerm))
+	} else {
+		headersHXReplaceURL(w, "/archive/")
+	}
+	headersNoCache(w)
+	if isHXRequest(r) {
+		render(w, r, comArchiveList(todos, searchTerm, queryError), "comArchiveList")
+		return
+	}
+	render(w, r, pageArchive(todos, searchTerm, queryError), "pageArchive")
+}
+
+func (s *Server) handlePostTodoUnarchive(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, err := s.repo.Unarchive([]string{id}); err != nil {
+		internalErr(w, err, "unarchiving todo", slog.With(slog.String("id", id)))
+		return
+	}
+
+	if isHXRequest(r) {
+		s.handleGetArchive(w, r)
+		return
+	}
+
+	u := "/archive/"
+	if searchTerm := r.FormValue("term"); searchTerm != "" {
+		u += "?term=" + url.QueryEscape(searchTerm)
+	}
+	http.Redirect(w, r, u, http.StatusSeeOther)
+}
+
+func (s *Server) handlePostBulk(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	ids := r.PostForm["id"]
+	value := strings.TrimSpace(r.PostForm.Get("value"))
+	action := r.PostForm.Get("action")
+
+	var description string
+	var apply func(tx *repository.Tx) (int, error)
+	switch action {
+	case "done":
+		description = "Mark done"
+		apply = func(tx *repository.Tx) (int, error) { return tx.SetDone(ids, true) }
+	case "undone":
+		description = "Mark undone"
+		apply = func(tx *repository.Tx) (int, error) { return tx.SetDone(ids, false) }
+	case "delete":
+		description = "Delete"
+		apply = func(tx *repository.Tx) (int, error) { return tx.RemoveAll(ids) }
+	case "tag":
+		if value == "" {
+			http.Error(w, "tag is required", http.StatusBadRequest)
+			return
+		}
+		tag := strings.TrimPrefix(value, "#")
+		description = "Tag #" + tag
+		apply = func(tx *repository.Tx) (int, error) { return tx.AddTag(ids, tag) }
+	case "move":
+		description = "Move to list " + strconv.Quote(value)
+		apply = func(tx *repository.Tx) (int, error) { return tx.SetList(ids, value) }
+	default:
+		http.Error(w, "unknown action", http.StatusBadRequest)
+		return
+	}
+	// All changes of a bulk action are undone at once.
+	err := s.repo.UpdateUndoable(description, func(tx *repository.Tx) error {
+		_, err := apply(tx)
+		return err
+	})
+	if err != nil {
+		internalErr(w, err, "applying bulk action",
+			slog.With(slog.String("action", action), slog.Int("todos", len(ids))))
+		return
+	}
+
+	if isHXRequest(r) {
+		renderList(w, r, s.repo, r.PostForm.Get("term"))
+		return
+	}
+
+	redirectIndex(w, r)
+}
+
+func (s *Server) handlePostUndo(w http.ResponseWriter, r *http.Request) {
+	action, skipped, err := s.repo.Undo()
+	if errors.Is(err, repository.ErrNothingToUndo) {
+		http.Error(w, "nothing to undo", http.StatusConflict)
+		return
+	} else if err != nil {
+		internalErr(w, err, "undoing bulk action", slog.Default())
+		return
+	}
+	if skipped > 0 {
+		slog.Info("todos changed since were not undone",
+			slog.String("action", action), slog.Int("skipped", skipped))
+	}
+
+	if isHXRequest(r) {
+		renderList(w, r, s.repo, r.FormValue("term"))
+		return
+	}
+
+	redirectIndex(w, r)
+}
+
+func (s *Server) handlePostTodoMove(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	_, err := s.repo.Move(id, r.FormValue("after"), r.FormValue("before"))
+	if errors.Is(err, repository.ErrNotFound) {
+		http.Error(w, "todo not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		internalErr(w, err, "moving todo", slog.With(slog.String("id", id)))
+		return
+	}
+
+	if isHXRequest(r) {
+		renderList(w, r, s.repo, r.FormValue("term"))
+		return
+	}
+
+	redirectIndex(w, r)
+}
+
+func (s *Server) handlePostTodoRemind(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var at time.Time
+	if v := r.FormValue("remind"); v != "" && r.FormValue("clear") == "" {
+		var err error
+		// The value of a datetime-local input, interpreted in server local time.
+		if at, err = time.ParseInLocation(layoutDateTimeLocal, v, time.Local); err != nil {
+			http.Error(w, "invalid reminder time", http.StatusBadRequest)
+			return
+		}
+	}
+	if _, err := s.repo.SetReminder(id, at); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			http.Error(w, "todo not found", http.StatusNotFound)
+			return
+		}
+		internalErr(w, err, "setting reminder", slog.With(slog.String("id", id)))
+		return
+	}
+
+	if isHXRequest(r) {
+		renderList(w, r, s.repo, r.FormValue("term"))
+		return
+	}
+
+	redirectIndex(w, r)
+}
+
+func (s *Server) handleGetTodoTXT(w http.ResponseWriter, r *http.Request) {
+	todos, err := s.repo.All()
+	if err != nil {
+		internalErr(w, err, "getting all todos", slog.Default())
+		return
+	}
+	slices.Reverse(todos) // Oldest first like in a todo.txt file.
+
+	headersNoCache(w)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := todotxt.Write(w, todos); err != nil {
+		slog.Error("writing todo.txt", slog.Any("err", err))
+	}
+}
+
+func (s *Server) handlePostTodoTXT(w http.ResponseWriter, r *http.Request) {
+	f, err := formFileOrBody(r, "file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+
+	todos, err := todotxt.Read(f, time.Local)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("parsing todo.txt: %v", err), http.StatusBadRequest)
+		return
+	}
+	if _, err := s.repo.Import(todos, time.Now()); err != nil {
+		internalErr(w, err, "importing todo.txt", slog.Default())
+		return
+	}
+
+	redirectIndex(w, r)
+}
+
+func (s *Server) handleGetExportMD(w http.ResponseWriter, r *http.Request) {
+	todos, err := s.repo.All()
+	if err != nil {
+		internalErr(w, err, "getting all todos", slog.Default())
+		return
+	}
+	headersNoCache(w)
+	w.Header().Set("Content-Type", markdown.ContentType)
+	if err := markdown.Write(w, s.repo.Lists(), todos); err != nil {
+		slog.Error("writing markdown", slog.Any("err", err))
+	}
+}
+
+func (s *Server) handlePostImportMD(w http.ResponseWriter, r *http.Request) {
+	f, err := formFileOrBody(r, "file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+
+	todos, err := markdown.Read(f)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("parsing markdown: %v", err), http.StatusBadRequest)
+		return
+	}
+	// Import positions each todo before the previous one,
+	// the list keeps the order of the document.
+	slices.Reverse(todos)
+	if _, err := s.repo.Import(todos, time.Now()); err != nil {
+		internalErr(w, err, "importing markdown", slog.Default())
+		return
+	}
+
+	redirectIndex(w, r)
+}
+
+func (s *Server) handleGetBackup(w http.ResponseWriter, r *http.Request) {
+	if !isLoopback(r) {
+		const code = http.StatusForbidden
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+
+	// Buffer the archive to be able to respond with an error if the backup fails.
+	var buf bytes.Buffer
+	err := s.repo.Backup(&buf, time.Now())
+	if errors.Is(err, repository.ErrNotPersistent) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		internalErr(w, err, "creating backup", slog.Default())
+		return
+	}
+
+	headersNoCache(w)
+	w.Header().Set("Content-Type", "application/gzip")
+	_, _ = buf.WriteTo(w)
+}
+
+func (s *Server) handleGetListICS(w http.ResponseWriter, r *http.Request) {
+	list := r.PathValue("list")
+	todos, err := s.repo.InList(list)
+	if err != nil {
+		internalErr(w, err, "getting list todos", slog.With(slog.String("list", list)))
+		return
+	}
+
+	headersNoCache(w)
+	w.Header().Set("Content-Type", ical.ContentType)
+	if err := ical.Write(w, list, todos, time.Now()); err != nil {
+		slog.Error("writing ics", slog.Any("err", err))
+	}
+}
+
+func (s *Server) handlePostListICS(w http.ResponseWriter, r *http.Request) {
+	list := r.PathValue("list")
+	f, err := formFileOrBody(r, "file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer f.Close()
+
+	todos, err := ical.Read(f, time.Local)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("parsing ics: %v", err), http.StatusBadRequest)
+		return
+	}
+	for i := range todos {
+		todos[i].List = list
+	}
+	if _, err := s.repo.Import(todos, time.Now()); err != nil {
+		internalErr(w, err, "importing ics", slog.With(slog.String("list", list)))
+		return
+	}
+
+	redirectIndex(w, r)
+}
+
+// formFileOrBody returns the multipart form file by name if the request
+// is multipart/form-data, otherwise returns the request body.
+func formFileOrBody(r *http.Request, name string) (io.ReadCloser, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return r.Body, nil
+	}
+	f, _, err := r.FormFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading form file %q: %w", name, err)
+	}
+	return f, nil
+}
+
+// layoutDateTimeLocal is the value format of a datetime-local input.
+const layoutDateTimeLocal = "2006-01-02T15:04"
+
 func internalErr(w http.ResponseWriter, err error, msg string, log *slog.Logger) {
 	log.Error(msg, slog.Any("err", err))
 	const code = http.StatusInternalServerError
 	http.Error(w, http.StatusText(code), code)
 }
 
-func getPercentDone(todos []repository.Todo) string {
-	countDone := 0
-	for i := range todos {
-		if todos[i].Done {
-			countDone++
-		}
+func getPercentDone(done, total int) string {
+	if total < 1 {
+		return "0"
 	}
-	f := float64(countDone) / float64(len(todos))
+	f := float64(done) / float64(total)
 	return fmt.Sprintf("%d", int(f*100))
 }
 
+func isLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
 func requireHTMXRequest(_ http.ResponseWriter, _ *http.Request) (ok bool) {
 	return true
 	// http.Error(w, "not an HTMX request", http.StatusBadRequest)
@@ -200,39 +792,197 @@ func headersNoCache(w http.ResponseWriter) {
 	w.Header().Set("Expires", "0")
 }
 
+// PageSize is the number of todos rendered per page of the list.
+const PageSize = 50
+
+// listView is the data rendered by comList.
+type listView struct {
+	repository.Page
+	SearchTerm string
+	// Sort is the order of search results.
+	Sort repository.SortOrder
+	// PercentDone is the progress over all todos, not just the page.
+	PercentDone string
+	// Done is the number of done todos, not just on the page.
+	Done int
+	// QueryError is the message of an invalid SearchTerm.
+	QueryError string
+	// View is the slug of the saved search the list renders,
+	// empty for the index.
+	View string
+	// Undo is the bulk action the undo button reverts, empty if none.
+	Undo string
+}
+
+// pageURL returns the URL of the page at cursor of the list.
+func (v listView) pageURL(cursor string) string {
+	if v.View != "" {
+		return listURL(viewURL(v.View), "", repository.SortRelevance, cursor)
+	}
+	return listURL("/", v.SearchTerm, v.Sort, cursor)
+}
+
+// fetchTodos fetches the page at cursor, which is empty for the first page.
+// Search results are sorted by sort, all todos by their position.
+// An invalid searchTerm yields an empty list with QueryError set.
+// The search is aborted when ctx is canceled.
 func fetchTodos(
-	repo *repository.Repository, searchTerm string,
-) ([]repository.Todo, error) {
+	ctx context.Context, repo *repository.Repository, searchTerm string,
+	sort repository.SortOrder, cursor string,
+) (listView, error) {
+	v := listView{SearchTerm: searchTerm, Sort: sort}
+	var err error
 	if searchTerm == "" {
-		todos, err := repo.All()
+		// Read both in one transaction so that the progress matches the page.
+		err = repo.View(func(tx *repository.Tx) error {
+			if v.Page, err = tx.AllPage(cursor, PageSize); err != nil {
+				return err
+			}
+			done, total := tx.Progress()
+			v.Done, v.PercentDone = done, getPercentDone(done, total)
+			v.Undo = tx.UndoAction()
+			return nil
+		})
 		if err != nil {
-			return nil, fmt.Errorf("getting all todos: %w", err)
+			return listView{}, fmt.Errorf("getting all todos: %w", err)
 		}
-		return todos, nil
+		return v, nil
 	}
-	todos, err := repo.Find(searchTerm)
-	if err != nil {
-		return nil, fmt.Errorf("searching todos: %w", err)
+	_ = repo.View(func(tx *repository.Tx) error {
+		v.Done, _ = tx.Progress()
+		v.Undo = tx.UndoAction()
+		return nil
+	})
+	v.Page, err = repo.FindPage(ctx, searchTerm, sort, cursor, PageSize)
+	var qe *repository.QueryError
+	if errors.As(err, &qe) {
+		v.QueryError = qe.Error()
+		return v, nil
+	} else if err != nil {
+		return listView{}, fmt.Errorf("searching todos: %w", err)
+	}
+	return v, nil
+}
+
+// fetchAllTodos fetches all todos matching searchTerm without paging.
+func fetchAllTodos(
+	ctx context.Context, repo *repository.Repository, searchTerm string,
+) ([]repository.Todo, error) {
+	if searchTerm == "" {
+		return repo.All()
 	}
-	return todos, nil
+	return repo.Find(ctx, searchTerm)
 }
 
+// renderList renders the first page of the list
+// within the saved search "view" if given.
 func renderList(
 	w http.ResponseWriter, r *http.Request,
 	repo *repository.Repository, searchTerm string,
 ) {
-	todos, err := fetchTodos(repo, searchTerm)
+	sort, ok := sortOrder(r)
+	if !ok {
+		http.Error(w, "unsupported sort order", http.StatusBadRequest)
+		return
+	}
+	list, err := fetchTodos(r.Context(), repo, searchTerm, sort, "")
 	if err != nil {
 		internalErr(w, err, "fetching todos", slog.Default())
+		return
+	}
+	list.View = r.FormValue("view")
+	render(w, r, comList(list), "comList")
+}
+
+// sortOrder returns the "sort" form value, SortRelevance if empty.
+// Returns ok=false for unsupported sort orders.
+func sortOrder(r *http.Request) (sort repository.SortOrder, ok bool) {
+	sort = repository.SortOrder(r.FormValue("sort"))
+	if sort == "" {
+		return repository.SortRelevance, true
 	}
-	render(w, r, comList(todos, searchTerm), "comList")
+	return sort, sort.Valid()
 }
 
 func redirectIndex(w http.ResponseWriter, r *http.Request) {
-	u := "/"
-	searchTerm := r.FormValue("term")
+	sort, ok := sortOrder(r)
+	if !ok {
+		sort = repository.SortRelevance
+	}
+	http.Redirect(w, r, listURL("/", r.FormValue("term"), sort, ""), http.StatusSeeOther)
+}
+
+func listICSURL(list string) string {
+	return fmt.Sprintf("/lists/%s/todos.ics", url.PathEscape(list))
+}
+
+// highlighted renders the segments with matches in <mark> and all text escaped.
+// Unlike a templ loop it doesn't add whitespace between segments
+// that may be within a word.
+func highlighted(segments []repository.Segment) templ.Component {
+	return templ.ComponentFunc(func(_ context.Context, w io.Writer) error {
+		for _, s := range segments {
+			text := templ.EscapeString(s.Text)
+			if s.Match {
+				text = "<mark>" + text + "</mark>"
+			}
+			if _, err := io.WriteString(w, text); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// listURL returns the URL of the page at cursor of the list.
+// The default sort order SortRelevance is omitted.
+func listURL(path, searchTerm string, sort repository.SortOrder, cursor string) string {
+	q := url.Values{}
 	if searchTerm != "" {
-		u = fmt.Sprintf("/?term=%s", url.QueryEscape(searchTerm))
+		q.Set("term", searchTerm)
 	}
-	http.Redirect(w, r, u, http.StatusSeeOther)
+	if sort != repository.SortRelevance {
+		q.Set("sort", string(sort))
+	}
+	if cursor != "" {
+		q.Set("cursor", cursor)
+	}
+	if len(q) < 1 {
+		return path
+	}
+	return path + "?" + q.Encode()
+}
+
+// facetTerm returns searchTerm narrowed to the facet value of query,
+// or with query removed if searchTerm is already narrowed to it.
+// query may consist of multiple qualifiers, only the missing ones are added.
+func facetTerm(searchTerm, query string) (term string, active bool) {
+	fields := strings.Fields(searchTerm)
+	qualifiers := strings.Fields(query)
+	missing := slices.DeleteFunc(slices.Clone(qualifiers), func(q string) bool {
+		return slices.Contains(fields, q)
+	})
+	if len(missing) > 0 {
+		return strings.Join(append(fields, missing...), " "), false
+	}
+	fields = slices.DeleteFunc(fields, func(f string) bool {
+		return slices.Contains(qualifiers, f)
+	})
+	return strings.Join(fields, " "), true
+}
+
+// neighbourID returns the ID of todos[i] or "" if i is out of range.
+func neighbourID(todos []repository.Todo, i int) string {
+	if i < 0 || i >= len(todos) {
+		return ""
+	}
+	return todos[i].ID
+}
+
+// formatDue omits the time of day if due is at midnight.
+func formatDue(due time.Time) string {
+	if h, m, _ := due.Clock(); h == 0 && m == 0 {
+		return due.Format(time.DateOnly)
+	}
+	return due.Format("2006-01-02 15:04")
 }
@@ -0,0 +1,76 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/reminder"
+	"github.com/romshark/htmx-demo-todoapp/repository"
+	"github.com/romshark/htmx-demo-todoapp/server"
+	"github.com/romshark/htmx-demo-todoapp/views"
+	"github.com/romshark/htmx-demo-todoapp/webhook"
+)
+
+// newServer starts a server with an in-memory repository
+// containing a todo for each of the given titles.
+func newServer(t *testing.T, titles ...string) (*httptest.Server, *repository.Repository) {
+	t.Helper()
+	repo, err := repository.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = repo.Close() })
+	for _, title := range titles {
+		if _, err := repo.Add(title, false, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	hooks, err := webhook.Open("", reminder.RealClock{}, &http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := views.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(server.New(repo, hooks, v))
+	t.Cleanup(srv.Close)
+	return srv, repo
+}
+
+// newClient returns a client of srv keeping cookies like a browser.
+func newClient(t *testing.T, srv *httptest.Server) *http.Client {
+	t.Helper()
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &http.Client{Transport: srv.Client().Transport, Jar: jar}
+}
+
+// get returns the status and body of the response to a GET request of path.
+func get(t *testing.T, c *http.Client, srv *httptest.Server, path string) (int, string) {
+	t.Helper()
+	resp, err := c.Get(srv.URL + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func search(t *testing.T, c *http.Client, srv *httptest.Server, term string) {
+	t.Helper()
+	if code, _ := get(t, c, srv, "/?term="+url.QueryEscape(term)); code != http.StatusOK {
+		t.Fatalf("search %q: status %d", term, code)
+	}
+}
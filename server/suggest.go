@@ -0,0 +1,170 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// MaxSuggestions is the number of suggestions of each kind.
+const MaxSuggestions = 5
+
+// MaxRecentQueries is the number of past search queries
+// kept for suggestions per client.
+const MaxRecentQueries = 50
+
+// MaxRecentQueryClients is the number of clients whose queries are kept.
+const MaxRecentQueryClients = 1000
+
+// CookieQueryClient identifies the browser whose search queries are suggested.
+const CookieQueryClient = "query-client"
+
+// recentQueries are the most recent distinct search queries of each client.
+type recentQueries struct {
+	lock    sync.Mutex
+	clients map[string]*queryClient
+}
+
+type queryClient struct {
+	queries  []string // Most recent first.
+	lastSeen time.Time
+}
+
+// clientID returns the ID of the client in the CookieQueryClient cookie,
+// which is set to a new ID if the request has none.
+func clientID(w http.ResponseWriter, r *http.Request) string {
+	if c, err := r.Cookie(CookieQueryClient); err == nil && c.Value != "" {
+		return c.Value
+	}
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	id := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieQueryClient,
+		Value:    id,
+		Path:     "/",
+		MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return id
+}
+
+// add records q for client unless q is the beginning of a recorded query,
+// which happens when deleting characters. Recorded beginnings of q,
+// which are searched for while typing q, are replaced by it.
+func (h *recentQueries) add(client, q string) {
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	c, found := h.clients[client]
+	if !found {
+		if h.clients == nil {
+			h.clients = map[string]*queryClient{}
+		}
+		if len(h.clients) >= MaxRecentQueryClients {
+			h.evict()
+		}
+		c = &queryClient{}
+		h.clients[client] = c
+	}
+	c.lastSeen = time.Now()
+	if slices.ContainsFunc(c.queries, func(r string) bool {
+		return r != q && strings.HasPrefix(r, q)
+	}) {
+		return
+	}
+	c.queries = slices.DeleteFunc(c.queries, func(r string) bool {
+		return strings.HasPrefix(q, r)
+	})
+	c.queries = slices.Insert(c.queries, 0, q)
+	c.queries = c.queries[:min(len(c.queries), MaxRecentQueries)]
+}
+
+// evict forgets the least recently seen client.
+// Must be called with h.lock held.
+func (h *recentQueries) evict() {
+	var oldestID string
+	var oldest *queryClient
+	for id, c := range h.clients {
+		if oldest == nil || c.lastSeen.Before(oldest.lastSeen) {
+			oldestID, oldest = id, c
+		}
+	}
+	delete(h.clients, oldestID)
+}
+
+// matching returns up to limit queries recorded for client, most recent
+// first, that start with but aren't equal to prefix ignoring case.
+func (h *recentQueries) matching(client, prefix string, limit int) []string {
+	prefix = strings.ToLower(prefix)
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	c, found := h.clients[client]
+	if !found {
+		return nil
+	}
+	var r []string
+	for _, q := range c.queries {
+		if len(r) >= limit {
+			break
+		}
+		if l := strings.ToLower(q); l != prefix && strings.HasPrefix(l, prefix) {
+			r = append(r, q)
+		}
+	}
+	return r
+}
+
+// suggestion is an option of the search autocomplete listbox.
+type suggestion struct {
+	Kind  string
+	Label string
+	// Term replaces the search term when the suggestion is chosen.
+	Term string
+}
+
+// handleGetSuggest renders the options completing the search term "prefix":
+// past queries of the client, tags replacing the last word and titles
+// as phrases.
+func (s *Server) handleGetSuggest(w http.ResponseWriter, r *http.Request) {
+	prefix := r.FormValue("prefix")
+	found, err := s.repo.Suggest(r.Context(), prefix, MaxSuggestions)
+	if err != nil {
+		internalErr(w, err, "suggesting search terms", slog.Default())
+		return
+	}
+
+	var options []suggestion
+	c, err := r.Cookie(CookieQueryClient)
+	if strings.TrimSpace(prefix) != "" && err == nil {
+		for _, q := range s.queries.matching(c.Value, prefix, MaxSuggestions) {
+			options = append(options, suggestion{Kind: "Recent", Label: q, Term: q})
+		}
+	}
+	// Replace the last word, which the tags complete.
+	head := strings.TrimRightFunc(prefix, func(r rune) bool { return !unicode.IsSpace(r) })
+	for _, t := range found.Tags {
+		options = append(options, suggestion{
+			Kind: "Tag", Label: t, Term: head + "tag:" + t[1:],
+		})
+	}
+	for _, t := range found.Titles {
+		// Phrases can't contain quotes.
+		options = append(options, suggestion{
+			Kind: "Todo", Label: t, Term: `"` + strings.ReplaceAll(t, `"`, "") + `"`,
+		})
+	}
+
+	headersNoCache(w)
+	render(w, r, partSuggestions(options), "partSuggestions")
+}
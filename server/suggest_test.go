@@ -0,0 +1,43 @@
+package server_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRecentQueriesPerClient(t *testing.T) {
+	srv, _ := newServer(t, "Feed the cat", "Feed the dog")
+	alice, bob := newClient(t, srv), newClient(t, srv)
+
+	search(t, alice, srv, "feed cat")
+	search(t, bob, srv, "feed dog")
+
+	for _, tt := range []struct {
+		name    string
+		client  *http.Client
+		want    string
+		notWant string
+	}{
+		{"alice", alice, "feed cat", "feed dog"},
+		{"bob", bob, "feed dog", "feed cat"},
+	} {
+		code, body := get(t, tt.client, srv, "/suggest?prefix=feed")
+		if code != http.StatusOK {
+			t.Fatalf("%s: status %d", tt.name, code)
+		}
+		if !strings.Contains(body, tt.want) {
+			t.Errorf("%s isn't suggested %q:\n%s", tt.name, tt.want, body)
+		}
+		if strings.Contains(body, tt.notWant) {
+			t.Errorf("%s is suggested another client's query %q:\n%s",
+				tt.name, tt.notWant, body)
+		}
+	}
+
+	// Clients without a cookie aren't suggested any queries.
+	_, body := get(t, &http.Client{}, srv, "/suggest?prefix=feed")
+	if strings.Contains(body, "feed ") {
+		t.Errorf("client without cookie is suggested queries:\n%s", body)
+	}
+}
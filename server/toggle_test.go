@@ -0,0 +1,129 @@
+package server_test
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/romshark/htmx-demo-todoapp/caldav"
+)
+
+func TestToggleVersion(t *testing.T) {
+	srv, repo := newServer(t, "Buy milk")
+	c := newClient(t, srv)
+	c.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	all, err := repo.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	todo := all[0]
+
+	_, body := get(t, c, srv, "/")
+	if !strings.Contains(body, `name="version" value="1"`) {
+		t.Fatal("missing version in toggle form")
+	}
+
+	toggle := func(version uint64) *http.Response {
+		t.Helper()
+		resp, err := c.PostForm(srv.URL+"/"+todo.ID+"/toggle/", url.Values{
+			"done":    {"true"},
+			"version": {strconv.FormatUint(version, 10)},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	renamed, err := repo.Rename(todo.ID, "Buy oat milk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The form was rendered before the rename.
+	resp := toggle(todo.Version)
+	if resp.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("expected status %d, got %d", http.StatusPreconditionFailed, resp.StatusCode)
+	}
+	if got := resp.Header.Get("ETag"); got != caldav.ETag(renamed) {
+		t.Errorf("expected ETag %s, got %s", caldav.ETag(renamed), got)
+	}
+	if todo, _ = repo.Get(todo.ID); todo.Done {
+		t.Fatal("stale form marked the todo done")
+	}
+
+	resp = toggle(renamed.Version)
+	if resp.StatusCode != http.StatusSeeOther {
+		t.Fatalf("expected status %d, got %d", http.StatusSeeOther, resp.StatusCode)
+	}
+	if todo, _ = repo.Get(todo.ID); !todo.Done {
+		t.Fatal("todo not done")
+	}
+	if got := resp.Header.Get("ETag"); got != caldav.ETag(todo) {
+		t.Errorf("expected ETag %s, got %s", caldav.ETag(todo), got)
+	}
+}
+
+func TestToggleWithoutDone(t *testing.T) {
+	srv, repo := newServer(t, "Buy milk")
+	c := newClient(t, srv)
+	c.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	all, err := repo.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := all[0].ID
+
+	toggle := func(form url.Values, ifMatch string) int {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodPost, srv.URL+"/"+id+"/toggle/",
+			strings.NewReader(form.Encode()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		resp, err := c.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	check := func(done bool, version uint64) {
+		t.Helper()
+		todo, err := repo.Get(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if todo.Done != done || todo.Version != version {
+			t.Fatalf("done %t version %d, want done %t version %d",
+				todo.Done, todo.Version, done, version)
+		}
+	}
+
+	if code := toggle(url.Values{}, ""); code != http.StatusBadRequest {
+		t.Fatalf("unconditional toggle: status %d", code)
+	}
+	check(false, 1)
+	if code := toggle(url.Values{"version": {"1"}}, ""); code != http.StatusSeeOther {
+		t.Fatalf("toggle version 1: status %d", code)
+	}
+	check(true, 2)
+	if code := toggle(url.Values{}, `"1"`); code != http.StatusPreconditionFailed {
+		t.Fatalf("toggle stale If-Match: status %d", code)
+	}
+	check(true, 2)
+	if code := toggle(url.Values{}, `"2"`); code != http.StatusSeeOther {
+		t.Fatalf("toggle If-Match: status %d", code)
+	}
+	check(false, 3)
+}
@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/url"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+	"github.com/romshark/htmx-demo-todoapp/views"
+)
+
+// handleGetView renders the todos matching a saved search.
+// HTMX requests get the list only, which the view page
+// reloads whenever todos change.
+func (s *Server) handleGetView(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	v, err := s.views.Get(slug)
+	if errors.Is(err, views.ErrNotFound) {
+		http.Error(w, "view not found", http.StatusNotFound)
+		return
+	} else if err != nil {
+		internalErr(w, err, "getting view", slog.With(slog.String("slug", slug)))
+		return
+	}
+
+	list, err := fetchTodos(r.Context(), s.repo, v.Term, v.Sort, r.FormValue("cursor"))
+	if errors.Is(err, repository.ErrInvalidCursor) {
+		http.Error(w, "invalid cursor", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		internalErr(w, err, "fetching view todos", slog.With(slog.String("slug", slug)))
+		return
+	}
+	list.View = v.Slug
+
+	headersNoCache(w)
+	if isHXRequest(r) {
+		render(w, r, comList(list), "comList")
+		return
+	}
+	render(w, r, pageView(v, list, s.views.All()), "pageView")
+}
+
+// handlePostViews saves the search "term" sorted by "sort" as "name".
+func (s *Server) handlePostViews(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	sort, ok := sortOrder(r)
+	if !ok {
+		http.Error(w, "unsupported sort order", http.StatusBadRequest)
+		return
+	}
+	v, err := s.views.Save(r.PostForm.Get("name"), r.PostForm.Get("term"), sort)
+	if errors.Is(err, views.ErrExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, viewURL(v.Slug), http.StatusSeeOther)
+}
+
+func (s *Server) handlePostViewDelete(w http.ResponseWriter, r *http.Request) {
+	slug := r.PathValue("slug")
+	if err := s.views.Remove(slug); err != nil {
+		if errors.Is(err, views.ErrNotFound) {
+			http.Error(w, "view not found", http.StatusNotFound)
+			return
+		}
+		internalErr(w, err, "removing view", slog.With(slog.String("slug", slug)))
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func viewURL(slug string) string {
+	return "/views/" + url.PathEscape(slug) + "/"
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+	"github.com/romshark/htmx-demo-todoapp/webhook"
+)
+
+func (s *Server) handleGetWebhooks(w http.ResponseWriter, r *http.Request) {
+	headersNoCache(w)
+	if isHXRequest(r) {
+		render(w, r, comDeliveryLog(s.hooks.Deliveries()), "comDeliveryLog")
+		return
+	}
+	render(w, r, pageWebhooks(s.hooks.Hooks(), s.hooks.Deliveries()), "pageWebhooks")
+}
+
+func (s *Server) handlePostWebhooks(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	events := make([]repository.EventType, len(r.PostForm["event"]))
+	for i, e := range r.PostForm["event"] {
+		events[i] = repository.EventType(e)
+	}
+	_, err := s.hooks.Register(r.PostForm.Get("url"), r.PostForm.Get("secret"), events)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, r, "/webhooks/", http.StatusSeeOther)
+}
+
+func (s *Server) handlePostWebhookDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := s.hooks.Remove(id); err != nil {
+		if errors.Is(err, webhook.ErrNotFound) {
+			http.Error(w, "webhook not found", http.StatusNotFound)
+			return
+		}
+		internalErr(w, err, "removing webhook", slog.With(slog.String("id", id)))
+		return
+	}
+	http.Redirect(w, r, "/webhooks/", http.StatusSeeOther)
+}
+
+func hasPending(deliveries []webhook.Delivery) bool {
+	for _, d := range deliveries {
+		if d.Status == webhook.StatusPending {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+// Package todotxt reads and writes the todo.txt format
+// (see https://github.com/todotxt/todo.txt) mapping it onto repository.Todo.
+package todotxt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// DateLayout is the todo.txt date format.
+const DateLayout = "2006-01-02"
+
+// DueTimeLayout is the format of due dates with a time of day,
+// which todo.txt doesn't define.
+const DueTimeLayout = "2006-01-02T15:04"
+
+// escape is prefixed to title words that would otherwise be read
+// as a project, context, key:value pair or a leading marker.
+const escape = `\`
+
+const (
+	keyDue      = "due"
+	keyPriority = "pri"
+	keyList     = "list"
+	keyRRule    = "rrule"
+)
+
+// Write writes todos to w, one todo per line.
+func Write(w io.Writer, todos []repository.Todo) error {
+	for _, t := range todos {
+		if _, err := io.WriteString(w, FormatLine(t)+"\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read parses all todos from r skipping empty lines.
+// Dates are interpreted in loc.
+func Read(r io.Reader, loc *time.Location) ([]repository.Todo, error) {
+	var todos []repository.Todo
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		if strings.TrimSpace(sc.Text()) == "" {
+			continue
+		}
+		t, err := ParseLine(sc.Text(), loc)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		todos = append(todos, t)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return todos, nil
+}
+
+// FormatLine formats t as a single todo.txt line.
+// Since completed tasks can't have a priority in todo.txt,
+// the priority of a done todo is written as "pri:X" instead.
+// Projects and contexts within the title keep their place, the others
+// are appended. Title words that ParseLine would read as anything but
+// title text are escaped with a backslash. Tags ending the title
+// can't be told apart from appended ones and are read back as such.
+func FormatLine(t repository.Todo) string {
+	var b strings.Builder
+	if t.Done {
+		b.WriteString("x ")
+	} else if t.Priority != repository.PriorityNone {
+		b.WriteString("(" + t.Priority.String() + ") ")
+	}
+	if t.Done && !t.Completed.IsZero() && !t.Created.IsZero() {
+		// The completion date is only allowed if followed by the creation date.
+		b.WriteString(t.Completed.Format(DateLayout) + " ")
+	}
+	if !t.Created.IsZero() {
+		b.WriteString(t.Created.Format(DateLayout) + " ")
+	}
+	projects, contexts := slices.Clone(t.Projects), slices.Clone(t.Contexts)
+	for i, word := range strings.Fields(t.Title) {
+		if i > 0 {
+			b.WriteByte(' ')
+		}
+		switch {
+		case isTag(word, '+') && remove(&projects, word[1:]):
+		case isTag(word, '@') && remove(&contexts, word[1:]):
+		case isSpecial(word, i == 0):
+			word = escape + word
+		}
+		b.WriteString(word)
+	}
+	for _, p := range projects {
+		b.WriteString(" +" + p)
+	}
+	for _, c := range contexts {
+		b.WriteString(" @" + c)
+	}
+	if t.Done && t.Priority != repository.PriorityNone {
+		b.WriteString(" " + keyPriority + ":" + t.Priority.String())
+	}
+	if t.List != "" && t.List != repository.DefaultList {
+		b.WriteString(" " + keyList + ":" + t.List)
+	}
+	if t.Recurrence != "" {
+		b.WriteString(" " + keyRRule + ":" + t.Recurrence)
+	}
+	if !t.Due.IsZero() {
+		layout := DateLayout
+		if h, m, s := t.Due.Clock(); h != 0 || m != 0 || s != 0 {
+			layout = DueTimeLayout
+		}
+		b.WriteString(" " + keyDue + ":" + t.Due.Format(layout))
+	}
+	keys := make([]string, 0, len(t.Meta))
+	for k := range t.Meta {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		b.WriteString(" " + k + ":" + t.Meta[k])
+	}
+	return b.String()
+}
+
+// isTag reports whether word is a project (sigil '+') or context ('@') tag.
+func isTag(word string, sigil byte) bool {
+	return len(word) > 1 && word[0] == sigil
+}
+
+// remove removes the first occurrence of v from s and reports whether it did.
+func remove(s *[]string, v string) bool {
+	i := slices.Index(*s, v)
+	if i < 0 {
+		return false
+	}
+	*s = slices.Delete(*s, i, i+1)
+	return true
+}
+
+// isSpecial reports whether ParseLine wouldn't read word as title text.
+// The first word is special if it would be read as a completion marker,
+// a priority or a date.
+func isSpecial(word string, first bool) bool {
+	if strings.HasPrefix(word, escape) || isTag(word, '+') || isTag(word, '@') {
+		return true
+	}
+	if _, _, ok := cutKeyValue(word); ok {
+		return true
+	}
+	if !first {
+		return false
+	}
+	if _, err := time.Parse(DateLayout, word); err == nil {
+		return true
+	}
+	return word == "x" || isPriority(word)
+}
+
+// isPriority reports whether word is a priority like "(A)".
+func isPriority(word string) bool {
+	return len(word) == 3 && word[0] == '(' && word[2] == ')' &&
+		word[1] >= 'A' && word[1] <= 'Z'
+}
+
+// ParseLine parses a single todo.txt line. Projects and contexts are stored
+// in their respective fields and kept in the title unless they trail it,
+// key:value pairs are removed from it. A leading backslash escapes a title word.
+func ParseLine(line string, loc *time.Location) (t repository.Todo, err error) {
+	rest := strings.TrimSpace(line)
+
+	if after, ok := strings.CutPrefix(rest, "x "); ok {
+		t.Done, rest = true, strings.TrimLeft(after, " ")
+	}
+	// Some clients keep the priority on completed tasks, accept it leniently.
+	if len(rest) >= 4 && isPriority(rest[:3]) && rest[3] == ' ' {
+		t.Priority, rest = repository.Priority(rest[1]), strings.TrimLeft(rest[4:], " ")
+	}
+
+	// Up to two leading dates: [completion date] creation date.
+	var dates []time.Time
+	for len(dates) < 2 {
+		word, after, _ := strings.Cut(rest, " ")
+		d, err := time.ParseInLocation(DateLayout, word, loc)
+		if err != nil {
+			break
+		}
+		dates, rest = append(dates, d), strings.TrimLeft(after, " ")
+	}
+	switch {
+	case len(dates) == 2 && t.Done:
+		t.Completed, t.Created = dates[0], dates[1]
+	case len(dates) == 2:
+		return repository.Todo{}, fmt.Errorf(
+			"completion date on incomplete task: %s", dates[0].Format(DateLayout),
+		)
+	case len(dates) == 1:
+		t.Created = dates[0]
+	}
+
+	// Tags within the title are kept in it,
+	// those trailing it like key:value pairs aren't.
+	words := strings.Fields(rest)
+	end := len(words)
+	for end > 0 && isTrailing(words[end-1]) {
+		end--
+	}
+	var title []string
+	for i, word := range words {
+		inTitle := i < end
+		switch {
+		case strings.HasPrefix(word, escape):
+			title = append(title, word[len(escape):])
+		case isTag(word, '+'):
+			t.Projects = append(t.Projects, word[1:])
+			if inTitle {
+				title = append(title, word)
+			}
+		case isTag(word, '@'):
+			t.Contexts = append(t.Contexts, word[1:])
+			if inTitle {
+				title = append(title, word)
+			}
+		default:
+			k, v, ok := cutKeyValue(word)
+			if !ok {
+				title = append(title, word)
+				continue
+			}
+			if err := setKeyValue(&t, k, v, loc); err != nil {
+				return repository.Todo{}, err
+			}
+		}
+	}
+	t.Title = strings.Join(title, " ")
+	return t, nil
+}
+
+// isTrailing reports whether word may follow the title.
+func isTrailing(word string) bool {
+	_, _, ok := cutKeyValue(word)
+	return ok || isTag(word, '+') || isTag(word, '@')
+}
+
+// cutKeyValue returns ok=false if word isn't a "key:value" pair.
+// URLs like "https://example.com" are not considered pairs.
+// Only due dates may contain further colons (see DueTimeLayout).
+func cutKeyValue(word string) (key, value string, ok bool) {
+	key, value, ok = strings.Cut(word, ":")
+	if !ok || key == "" || value == "" || strings.HasPrefix(value, "//") ||
+		(key != keyDue && strings.Contains(value, ":")) {
+		return "", "", false
+	}
+	return key, value, true
+}
+
+func setKeyValue(t *repository.Todo, key, value string, loc *time.Location) error {
+	switch key {
+	case keyDue:
+		layout := DateLayout
+		if strings.Contains(value, "T") {
+			layout = DueTimeLayout
+		}
+		d, err := time.ParseInLocation(layout, value, loc)
+		if err != nil {
+			return fmt.Errorf("invalid due date: %q", value)
+		}
+		t.Due = d
+	case keyList:
+		t.List = value
+	case keyRRule:
+		r, err := repository.ParseRecurrence(value)
+		if err != nil {
+			return fmt.Errorf("invalid rrule: %w", err)
+		}
+		t.Recurrence = r.String()
+	case keyPriority:
+		if t.Done && len(value) == 1 && value[0] >= 'A' && value[0] <= 'Z' {
+			t.Priority = repository.Priority(value[0])
+			return nil
+		}
+		fallthrough
+	default:
+		if t.Meta == nil {
+			t.Meta = map[string]string{}
+		}
+		t.Meta[key] = value
+	}
+	return nil
+}
@@ -0,0 +1,175 @@
+package todotxt_test
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+	"github.com/romshark/htmx-demo-todoapp/todotxt"
+)
+
+var update = flag.Bool("update", false, "update the golden files")
+
+// TestReadWriteGolden reads testdata/import.txt and compares
+// the written todos to testdata/import.golden.
+func TestReadWriteGolden(t *testing.T) {
+	f, err := os.Open(filepath.Join("testdata", "import.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	todos, err := todotxt.Read(f, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	if err := todotxt.Write(&b, todos); err != nil {
+		t.Fatal(err)
+	}
+
+	golden := filepath.Join("testdata", "import.golden")
+	if *update {
+		if err := os.WriteFile(golden, b.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.String() != string(want) {
+		t.Errorf("written todos differ from %s:\n%s", golden, b.String())
+	}
+
+	// The written todos must read back identically.
+	again, err := todotxt.Read(&b, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again, todos) {
+		t.Errorf("round trip changed todos:\n got %#v\nwant %#v", again, todos)
+	}
+}
+
+func date(s string) time.Time {
+	d, err := time.Parse(todotxt.DueTimeLayout, s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, td := range []struct {
+		name string
+		todo repository.Todo
+		line string
+	}{
+		{
+			name: "time",
+			todo: repository.Todo{Title: "Meeting at 10:30"},
+			line: `Meeting at \10:30`,
+		},
+		{
+			name: "plus",
+			todo: repository.Todo{Title: "Fix +1 votes"},
+			line: `Fix \+1 votes`,
+		},
+		{
+			name: "at",
+			todo: repository.Todo{Title: "Mail me@example.com"},
+			line: `Mail me@example.com`,
+		},
+		{
+			name: "leading x",
+			todo: repository.Todo{Title: "x marks the spot"},
+			line: `\x marks the spot`,
+		},
+		{
+			name: "leading priority",
+			todo: repository.Todo{Title: "(A) first"},
+			line: `\(A) first`,
+		},
+		{
+			name: "leading date after created",
+			todo: repository.Todo{
+				Title: "2024-01-02 plan", Created: date("2024-01-01T00:00"),
+			},
+			line: `2024-01-01 \2024-01-02 plan`,
+		},
+		{
+			name: "backslash",
+			todo: repository.Todo{Title: `\n is a newline`},
+			line: `\\n is a newline`,
+		},
+		{
+			name: "tags in place",
+			todo: repository.Todo{
+				Title:    "Call +mom about @phone plans",
+				Projects: []string{"mom", "work"},
+				Contexts: []string{"phone"},
+			},
+			line: `Call +mom about @phone plans +work`,
+		},
+		{
+			name: "tag in title only once",
+			todo: repository.Todo{Title: "Vote +wiki today", Projects: []string{"wiki"}},
+			line: `Vote +wiki today`,
+		},
+		{
+			name: "due time",
+			todo: repository.Todo{Title: "Standup", Due: date("2024-01-02T09:00")},
+			line: `Standup due:2024-01-02T09:00`,
+		},
+		{
+			name: "due date",
+			todo: repository.Todo{Title: "Taxes", Due: date("2024-04-30T00:00")},
+			line: `Taxes due:2024-04-30`,
+		},
+		{
+			name: "done with priority",
+			todo: repository.Todo{
+				Title:     "Ship",
+				Done:      true,
+				Priority:  'A',
+				Created:   date("2024-01-01T00:00"),
+				Completed: date("2024-01-02T00:00"),
+			},
+			line: `x 2024-01-02 2024-01-01 Ship pri:A`,
+		},
+	} {
+		t.Run(td.name, func(t *testing.T) {
+			line := todotxt.FormatLine(td.todo)
+			if line != td.line {
+				t.Errorf("FormatLine = %q, want %q", line, td.line)
+			}
+			got, err := todotxt.ParseLine(line, time.UTC)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, td.todo) {
+				t.Errorf("ParseLine(%q) =\n%#v\nwant\n%#v", line, got, td.todo)
+			}
+		})
+	}
+}
+
+func TestParseLineErrors(t *testing.T) {
+	for _, line := range []string{
+		"2024-01-02 2024-01-01 completion date on open task",
+		"Taxes due:tomorrow",
+		"Gym rrule:FREQ=NEVER",
+	} {
+		if _, err := todotxt.ParseLine(line, time.UTC); err == nil {
+			t.Errorf("ParseLine(%q): expected error", line)
+		} else if strings.TrimSpace(err.Error()) == "" {
+			t.Errorf("ParseLine(%q): empty error", line)
+		}
+	}
+}
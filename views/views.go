@@ -0,0 +1,166 @@
+// Package views stores saved searches, which are rendered as virtual lists.
+package views
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// FileStore is the name of the file views are persisted in
+// within the data directory.
+const FileStore = "views.json"
+
+// View is a saved search.
+type View struct {
+	// Slug identifies the view in its URL and is derived from Name.
+	Slug string               `json:"slug"`
+	Name string               `json:"name"`
+	Term string               `json:"term"`
+	Sort repository.SortOrder `json:"sort"`
+}
+
+var (
+	ErrNotFound = errors.New("not found")
+	ErrExists   = errors.New("a view with this name already exists")
+)
+
+// Store is a persistent set of views.
+type Store struct {
+	// dir is the data directory, empty if views are kept in memory only.
+	dir string
+
+	lock  sync.Mutex
+	views []View // Sorted by name.
+}
+
+// Open opens the store persisting its views in dir.
+// Nothing is persisted if dir is empty.
+func Open(dir string) (*Store, error) {
+	s := &Store{dir: dir}
+	if dir == "" {
+		return s, nil
+	}
+	b, err := os.ReadFile(filepath.Join(dir, FileStore))
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading view store: %w", err)
+	}
+	if err := json.Unmarshal(b, &s.views); err != nil {
+		return nil, fmt.Errorf("decoding view store: %w", err)
+	}
+	return s, nil
+}
+
+// All returns all views sorted by name.
+func (s *Store) All() []View {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return slices.Clone(s.views)
+}
+
+// Get returns the view identified by slug.
+// Returns ErrNotFound if there is none.
+func (s *Store) Get(slug string) (View, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i := slices.IndexFunc(s.views, func(v View) bool { return v.Slug == slug })
+	if i < 0 {
+		return View{}, ErrNotFound
+	}
+	return s.views[i], nil
+}
+
+// Save saves the search term sorted by sort under name.
+// Returns ErrExists if name yields the slug of an existing view
+// and a *repository.QueryError if term is invalid.
+func (s *Store) Save(name, term string, sort repository.SortOrder) (View, error) {
+	name, term = strings.TrimSpace(name), strings.TrimSpace(term)
+	if name == "" {
+		return View{}, errors.New("name is required")
+	}
+	if term == "" {
+		return View{}, errors.New("search term is required")
+	}
+	if !sort.Valid() {
+		return View{}, fmt.Errorf("unsupported sort order: %q", sort)
+	}
+	if _, err := repository.ParseQuery(term, repository.QueryOptions{}); err != nil {
+		return View{}, err
+	}
+	slug := Slug(name)
+	if slug == "" {
+		return View{}, fmt.Errorf("invalid name: %q", name)
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if slices.ContainsFunc(s.views, func(v View) bool { return v.Slug == slug }) {
+		return View{}, ErrExists
+	}
+	v := View{Slug: slug, Name: name, Term: term, Sort: sort}
+	i, _ := slices.BinarySearchFunc(s.views, v, func(a, b View) int {
+		return strings.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name))
+	})
+	s.views = slices.Insert(s.views, i, v)
+	if err := s.persist(); err != nil {
+		return View{}, err
+	}
+	return v, nil
+}
+
+// Remove removes the view identified by slug.
+// Returns ErrNotFound if there is none.
+func (s *Store) Remove(slug string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	i := slices.IndexFunc(s.views, func(v View) bool { return v.Slug == slug })
+	if i < 0 {
+		return ErrNotFound
+	}
+	s.views = slices.Delete(s.views, i, i+1)
+	return s.persist()
+}
+
+// Slug returns the lower case letters and digits of name
+// with all other runs of characters replaced by a dash,
+// e.g. "urgent-this-week" for "Urgent this week!".
+func Slug(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	return b.String()
+}
+
+// persist atomically writes the store file. No-op if s.dir is empty.
+// Must be called with s.lock held.
+func (s *Store) persist() error {
+	if s.dir == "" {
+		return nil
+	}
+	b, err := json.Marshal(s.views)
+	if err != nil {
+		return fmt.Errorf("encoding view store: %w", err)
+	}
+	return repository.WriteFileAtomic(filepath.Join(s.dir, FileStore), b)
+}
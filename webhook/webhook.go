@@ -0,0 +1,447 @@
+// Package webhook delivers repository events to registered URLs.
+//
+// Every request body is signed with the hook's secret using HMAC-SHA256,
+// the signature is sent in the SignatureHeader as "sha256=<hex>".
+// Failed deliveries are retried with exponential backoff from a queue
+// that's persisted in the data directory to survive restarts.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+)
+
+// FileStore is the name of the file hooks and deliveries
+// are persisted in within the data directory.
+const FileStore = "webhooks.json"
+
+// Headers set on every delivery request.
+const (
+	SignatureHeader = "X-Todo-Signature"
+	EventHeader     = "X-Todo-Event"
+	DeliveryHeader  = "X-Todo-Delivery"
+)
+
+// Events are the event types hooks can subscribe to.
+var Events = []repository.EventType{
+	repository.EventCreated,
+	repository.EventToggled,
+	repository.EventDeleted,
+	repository.EventRenamed,
+}
+
+// Retry policy.
+const (
+	MaxAttempts    = 8
+	InitialBackoff = 10 * time.Second
+	MaxBackoff     = time.Hour
+	RequestTimeout = 10 * time.Second
+	// MaxLog is the number of finished deliveries kept in the delivery log.
+	MaxLog = 100
+)
+
+// Hook is a registered webhook.
+type Hook struct {
+	ID      string                 `json:"id"`
+	URL     string                 `json:"url"`
+	Secret  string                 `json:"secret"`
+	Events  []repository.EventType `json:"events"`
+	Created time.Time              `json:"created"`
+}
+
+// Status is the state of a delivery.
+type Status string
+
+const (
+	StatusPending   Status = "pending"
+	StatusDelivered Status = "delivered"
+	StatusFailed    Status = "failed"
+)
+
+// Delivery is a queued or finished delivery of an event to a hook.
+type Delivery struct {
+	ID          string               `json:"id"`
+	HookID      string               `json:"hookId"`
+	URL         string               `json:"url"`
+	Event       repository.EventType `json:"event"`
+	Payload     json.RawMessage      `json:"payload"`
+	Status      Status               `json:"status"`
+	Attempts    int                  `json:"attempts"`
+	NextAttempt time.Time            `json:"nextAttempt"`
+	StatusCode  int                  `json:"statusCode,omitempty"`
+	LastError   string               `json:"lastError,omitempty"`
+	Created     time.Time            `json:"created"`
+	Finished    time.Time            `json:"finished"`
+}
+
+// Payload is the JSON request body of a delivery.
+type Payload struct {
+	DeliveryID string               `json:"deliveryId"`
+	Event      repository.EventType `json:"event"`
+	Time       time.Time            `json:"time"`
+	Todo       repository.Todo      `json:"todo"`
+	// Previous is the todo before it was renamed for todo.renamed events.
+	Previous *repository.Todo `json:"previous,omitempty"`
+}
+
+// storeFile is the JSON representation of the store file.
+type storeFile struct {
+	Hooks      []Hook     `json:"hooks"`
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+var ErrNotFound = errors.New("not found")
+
+// Clock provides the current time and timers.
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+// Dispatcher enqueues a delivery for every repository event
+// a hook is subscribed to and sends them in Run.
+type Dispatcher struct {
+	// dir is the data directory, empty if hooks are kept in memory only.
+	dir    string
+	clock  Clock
+	client *http.Client
+	wake   chan struct{}
+
+	lock       sync.Mutex
+	hooks      []Hook
+	deliveries []Delivery // Oldest first.
+}
+
+// Open creates a dispatcher persisting its hooks and queue in dir.
+// Nothing is persisted if dir is empty.
+func Open(dir string, clock Clock, client *http.Client) (*Dispatcher, error) {
+	d := &Dispatcher{
+		dir:    dir,
+		clock:  clock,
+		client: client,
+		wake:   make(chan struct{}, 1),
+	}
+	if dir == "" {
+		return d, nil
+	}
+	b, err := os.ReadFile(filepath.Join(dir, FileStore))
+	if errors.Is(err, fs.ErrNotExist) {
+		return d, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading webhook store: %w", err)
+	}
+	var f storeFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decoding webhook store: %w", err)
+	}
+	d.hooks, d.deliveries = f.Hooks, f.Deliveries
+	return d, nil
+}
+
+// Hooks returns all registered hooks.
+func (d *Dispatcher) Hooks() []Hook {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	return slices.Clone(d.hooks)
+}
+
+// Deliveries returns the queued and finished deliveries, newest first.
+func (d *Dispatcher) Deliveries() []Delivery {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	l := slices.Clone(d.deliveries)
+	slices.Reverse(l)
+	return l
+}
+
+// Register adds a new hook. A random secret is generated if secret is empty.
+func (d *Dispatcher) Register(
+	rawURL, secret string, events []repository.EventType,
+) (Hook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Hook{}, fmt.Errorf("invalid URL: %q", rawURL)
+	}
+	if len(events) < 1 {
+		return Hook{}, errors.New("no events selected")
+	}
+	for _, e := range events {
+		if !slices.Contains(Events, e) {
+			return Hook{}, fmt.Errorf("unsupported event: %q", e)
+		}
+	}
+	if secret == "" {
+		secret = randomID(16)
+	}
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	h := Hook{
+		ID:      randomID(8),
+		URL:     u.String(),
+		Secret:  secret,
+		Events:  events,
+		Created: d.clock.Now(),
+	}
+	d.hooks = append(d.hooks, h)
+	if err := d.persist(); err != nil {
+		return Hook{}, err
+	}
+	return h, nil
+}
+
+// Remove removes a hook and drops its pending deliveries.
+// Returns ErrNotFound if id isn't found.
+func (d *Dispatcher) Remove(id string) error {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	i := slices.IndexFunc(d.hooks, func(h Hook) bool { return h.ID == id })
+	if i < 0 {
+		return ErrNotFound
+	}
+	d.hooks = slices.Delete(d.hooks, i, i+1)
+	d.deliveries = slices.DeleteFunc(d.deliveries, func(v Delivery) bool {
+		return v.HookID == id && v.Status == StatusPending
+	})
+	return d.persist()
+}
+
+// Enqueue queues a delivery of e for every hook subscribed to it.
+// It's registered as a repository event listener in Run.
+// The queue is persisted before Enqueue returns, so deliveries of events
+// emitted after the repository update was persisted can only be lost if
+// persisting the queue fails, which is logged.
+func (d *Dispatcher) Enqueue(e repository.Event) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	now := d.clock.Now()
+	enqueued := false
+	for _, h := range d.hooks {
+		if !slices.Contains(h.Events, e.Type) {
+			continue
+		}
+		p := Payload{DeliveryID: randomID(8), Event: e.Type, Time: e.Time, Todo: e.Todo}
+		if e.Type == repository.EventRenamed {
+			p.Previous = &e.Previous
+		}
+		body, err := json.Marshal(p)
+		if err != nil {
+			slog.Error("encoding webhook payload", slog.Any("err", err))
+			continue
+		}
+		d.deliveries = append(d.deliveries, Delivery{
+			ID:          p.DeliveryID,
+			HookID:      h.ID,
+			URL:         h.URL,
+			Event:       e.Type,
+			Payload:     body,
+			Status:      StatusPending,
+			NextAttempt: now,
+			Created:     now,
+		})
+		enqueued = true
+	}
+	if !enqueued {
+		return
+	}
+	if err := d.persist(); err != nil {
+		slog.Error("persisting webhook queue", slog.Any("err", err))
+	}
+	select {
+	case d.wake <- struct{}{}:
+	default: // Already woken.
+	}
+}
+
+// Run subscribes to the repository events and sends
+// queued deliveries until ctx is canceled.
+func (d *Dispatcher) Run(ctx context.Context, repo *repository.Repository) {
+	unsubscribe := repo.Subscribe(d.Enqueue)
+	defer unsubscribe()
+
+	for {
+		next := d.sendDue(ctx)
+		wait := MaxBackoff
+		if !next.IsZero() {
+			wait = min(wait, next.Sub(d.clock.Now()))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-d.wake:
+		case <-d.clock.After(wait):
+		}
+	}
+}
+
+// sendDue sends all deliveries that are due one at a time in the order
+// they were enqueued and returns the time of the next pending attempt.
+func (d *Dispatcher) sendDue(ctx context.Context) (next time.Time) {
+	for ctx.Err() == nil {
+		d.lock.Lock()
+		now := d.clock.Now()
+		i := slices.IndexFunc(d.deliveries, func(v Delivery) bool {
+			return v.Status == StatusPending && !v.NextAttempt.After(now)
+		})
+		if i < 0 {
+			next = time.Time{}
+			for _, v := range d.deliveries {
+				if v.Status == StatusPending &&
+					(next.IsZero() || v.NextAttempt.Before(next)) {
+					next = v.NextAttempt
+				}
+			}
+			d.lock.Unlock()
+			return next
+		}
+		v := d.deliveries[i]
+		secret := ""
+		if h := slices.IndexFunc(d.hooks, func(h Hook) bool {
+			return h.ID == v.HookID
+		}); h >= 0 {
+			secret = d.hooks[h].Secret
+		}
+		d.lock.Unlock()
+
+		code, err := d.send(ctx, v, secret)
+		if ctx.Err() != nil {
+			return time.Time{} // Retry after restart.
+		}
+		d.finishAttempt(v.ID, code, err)
+	}
+	return time.Time{}
+}
+
+// finishAttempt records the result of an attempt and schedules the next one
+// if it failed and there are attempts left.
+func (d *Dispatcher) finishAttempt(id string, code int, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	i := slices.IndexFunc(d.deliveries, func(v Delivery) bool { return v.ID == id })
+	if i < 0 {
+		return // The hook was removed in the meantime.
+	}
+	now := d.clock.Now()
+	v := &d.deliveries[i]
+	v.Attempts++
+	v.StatusCode = code
+	switch {
+	case err == nil:
+		v.Status, v.LastError, v.Finished = StatusDelivered, "", now
+	case v.Attempts >= MaxAttempts:
+		v.Status, v.LastError, v.Finished = StatusFailed, err.Error(), now
+	default:
+		v.LastError = err.Error()
+		v.NextAttempt = now.Add(Backoff(v.Attempts))
+	}
+	if v.Status != StatusPending {
+		slog.Info("webhook delivery finished",
+			slog.String("delivery", v.ID),
+			slog.String("status", string(v.Status)),
+			slog.Int("attempts", v.Attempts))
+	}
+	d.trimLog()
+	if err := d.persist(); err != nil {
+		slog.Error("persisting webhook queue", slog.Any("err", err))
+	}
+}
+
+// Backoff returns the delay before the next attempt after the given number
+// of failed attempts, doubling from InitialBackoff up to MaxBackoff.
+func Backoff(attempts int) time.Duration {
+	b := InitialBackoff
+	for i := 1; i < attempts && b < MaxBackoff; i++ {
+		b *= 2
+	}
+	return min(b, MaxBackoff)
+}
+
+// send returns the status code of the response if any.
+func (d *Dispatcher) send(ctx context.Context, v Delivery, secret string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(
+		ctx, http.MethodPost, v.URL, bytes.NewReader(v.Payload),
+	)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, string(v.Event))
+	req.Header.Set(DeliveryHeader, v.ID)
+	req.Header.Set(SignatureHeader, Sign(secret, v.Payload))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign returns the signature header value of body: "sha256=" followed by
+// the hex encoded HMAC-SHA256 of body using secret as the key.
+func Sign(secret string, body []byte) string {
+	m := hmac.New(sha256.New, []byte(secret))
+	m.Write(body)
+	return "sha256=" + hex.EncodeToString(m.Sum(nil))
+}
+
+// trimLog drops the oldest finished deliveries beyond MaxLog.
+// Must be called with d.lock held.
+func (d *Dispatcher) trimLog() {
+	finished := 0
+	for _, v := range d.deliveries {
+		if v.Status != StatusPending {
+			finished++
+		}
+	}
+	d.deliveries = slices.DeleteFunc(d.deliveries, func(v Delivery) bool {
+		if finished > MaxLog && v.Status != StatusPending {
+			finished--
+			return true
+		}
+		return false
+	})
+}
+
+// persist atomically writes the store file. No-op if d.dir is empty.
+// Must be called with d.lock held.
+func (d *Dispatcher) persist() error {
+	if d.dir == "" {
+		return nil
+	}
+	b, err := json.Marshal(storeFile{Hooks: d.hooks, Deliveries: d.deliveries})
+	if err != nil {
+		return fmt.Errorf("encoding webhook store: %w", err)
+	}
+	return repository.WriteFileAtomic(filepath.Join(d.dir, FileStore), b)
+}
+
+func randomID(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
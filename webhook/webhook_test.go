@@ -0,0 +1,304 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/romshark/htmx-demo-todoapp/repository"
+	"github.com/romshark/htmx-demo-todoapp/webhook"
+)
+
+// fakeClock is a webhook.Clock that only moves when advanced.
+// Every call to After is reported on sleeps.
+type fakeClock struct {
+	lock   sync.Mutex
+	now    time.Time
+	timers []timer
+	sleeps chan time.Duration
+}
+
+type timer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+func newFakeClock(now time.Time) *fakeClock {
+	return &fakeClock{now: now, sleeps: make(chan time.Duration, 16)}
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	t := timer{at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+	} else {
+		c.timers = append(c.timers, t)
+	}
+	c.sleeps <- d
+	return t.c
+}
+
+// Advance moves the clock forward by d and fires all expired timers.
+func (c *fakeClock) Advance(d time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.now = c.now.Add(d)
+	pending := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = pending
+}
+
+// awaitSleep waits until the dispatcher sleeps for d.
+func (c *fakeClock) awaitSleep(t *testing.T, d time.Duration) {
+	t.Helper()
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case got := <-c.sleeps:
+			if got == d {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("dispatcher didn't sleep for %v", d)
+		}
+	}
+}
+
+// request is a delivery request received by the receiver.
+type request struct {
+	Header http.Header
+	Body   []byte
+}
+
+// receiver starts a webhook receiver responding with the given status codes
+// in order and 200 once they're used up.
+func receiver(t *testing.T, codes ...int) (*httptest.Server, <-chan request) {
+	t.Helper()
+	c := make(chan request, 8)
+	var lock sync.Mutex
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		lock.Lock()
+		code := http.StatusOK
+		if len(codes) > 0 {
+			code, codes = codes[0], codes[1:]
+		}
+		lock.Unlock()
+		w.WriteHeader(code)
+		c <- request{Header: r.Header, Body: body}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, c
+}
+
+func receive(t *testing.T, c <-chan request) request {
+	t.Helper()
+	select {
+	case r := <-c:
+		return r
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery received")
+	}
+	return request{}
+}
+
+func TestDeliveryRetried(t *testing.T) {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	repo, err := repository.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	srv, received := receiver(t, http.StatusInternalServerError)
+	clock := newFakeClock(start)
+
+	d, err := webhook.Open(dir, clock, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hook, err := d.Register(srv.URL, "s3cret", []repository.EventType{
+		repository.EventCreated,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() { d.Run(ctx, repo); close(stopped) }()
+	defer func() { cancel(); <-stopped }()
+	clock.awaitSleep(t, webhook.MaxBackoff)
+
+	id, err := repo.Add("Feed the cat", false, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The update event isn't subscribed to.
+	if _, err := repo.SetReminder(id, start.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	first := receive(t, received)
+	clock.awaitSleep(t, webhook.InitialBackoff)
+	checkRequest(t, first, hook.Secret, id)
+	l := d.Deliveries()
+	if len(l) != 1 {
+		t.Fatalf("%d deliveries, want 1", len(l))
+	}
+	if v := l[0]; v.Status != webhook.StatusPending || v.Attempts != 1 ||
+		v.StatusCode != http.StatusInternalServerError ||
+		!v.NextAttempt.Equal(start.Add(webhook.InitialBackoff)) {
+		t.Fatalf("after failed attempt: %#v", v)
+	}
+
+	clock.Advance(webhook.InitialBackoff)
+	retry := receive(t, received)
+	clock.awaitSleep(t, webhook.MaxBackoff)
+	checkRequest(t, retry, hook.Secret, id)
+	if retry.Header.Get(webhook.DeliveryHeader) != first.Header.Get(webhook.DeliveryHeader) {
+		t.Error("retry has a different delivery ID")
+	}
+	if v := d.Deliveries()[0]; v.Status != webhook.StatusDelivered || v.Attempts != 2 {
+		t.Fatalf("after retry: %#v", v)
+	}
+
+	// The delivery log survives restarts.
+	reopened, err := webhook.Open(dir, clock, srv.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := reopened.Deliveries(); len(l) != 1 || l[0].Status != webhook.StatusDelivered {
+		t.Fatalf("reopened deliveries: %#v", l)
+	}
+}
+
+func checkRequest(t *testing.T, r request, secret, todoID string) {
+	t.Helper()
+	if got, want := r.Header.Get(webhook.SignatureHeader), webhook.Sign(secret, r.Body); got != want {
+		t.Errorf("signature %q, want %q", got, want)
+	}
+	if got := r.Header.Get(webhook.EventHeader); got != string(repository.EventCreated) {
+		t.Errorf("event header %q", got)
+	}
+	var p webhook.Payload
+	if err := json.Unmarshal(r.Body, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Event != repository.EventCreated || p.Todo.ID != todoID ||
+		p.Todo.Title != "Feed the cat" || p.DeliveryID != r.Header.Get(webhook.DeliveryHeader) {
+		t.Errorf("payload: %#v", p)
+	}
+}
+
+func TestSign(t *testing.T) {
+	// echo -n '{"a":1}' | openssl dgst -sha256 -hmac key
+	const want = "sha256=88a67f24bbcdaed0e6c997404bb79a743baf44c6bab2f4c27328e3009d22e342"
+	if got := webhook.Sign("key", []byte(`{"a":1}`)); got != want {
+		t.Errorf("Sign: %q", got)
+	}
+}
+
+func TestQueuePersistedOnShutdown(t *testing.T) {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	repo, err := repository.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	clock := newFakeClock(start)
+	// The receiver is unreachable.
+	d, err := webhook.Open(dir, clock, &http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Register("http://127.0.0.1:1/", "", webhook.Events); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	stopped := make(chan struct{})
+	go func() { d.Run(ctx, repo); close(stopped) }()
+	clock.awaitSleep(t, webhook.MaxBackoff)
+
+	if _, err := repo.Add("One", false, start); err != nil {
+		t.Fatal(err)
+	}
+	clock.awaitSleep(t, webhook.InitialBackoff)
+	cancel()
+	<-stopped
+
+	reopened, err := webhook.Open(dir, clock, &http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := reopened.Deliveries()
+	if len(l) != 1 || l[0].Status != webhook.StatusPending || l[0].Attempts != 1 {
+		t.Fatalf("reopened deliveries: %#v", l)
+	}
+}
+
+func TestEnqueuePersisted(t *testing.T) {
+	start := time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	repo, err := repository.NewRepository()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer repo.Close()
+	clock := newFakeClock(start)
+	d, err := webhook.Open(dir, clock, &http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := d.Register("http://127.0.0.1:1/", "", []repository.EventType{
+		repository.EventCreated,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Run isn't started, as if the process crashed before sending.
+	defer repo.Subscribe(d.Enqueue)()
+	id, err := repo.Add("One", false, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := webhook.Open(dir, clock, &http.Client{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := reopened.Deliveries()
+	if len(l) != 1 || l[0].HookID != h.ID || l[0].Status != webhook.StatusPending ||
+		l[0].Attempts != 0 || !l[0].NextAttempt.Equal(start) {
+		t.Fatalf("reopened deliveries: %#v", l)
+	}
+	var p webhook.Payload
+	if err := json.Unmarshal(l[0].Payload, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Event != repository.EventCreated || p.Todo.ID != id {
+		t.Fatalf("payload %#v", p)
+	}
+}